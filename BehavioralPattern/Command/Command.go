@@ -21,11 +21,23 @@ Benefits:
 
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
 
 // Command defines the interface for executing operations
 type Command interface {
-	Execute()
+	Execute(ctx context.Context) error
 }
 
 // PrintCommand implements Command for printing messages
@@ -34,19 +46,40 @@ type PrintCommand struct {
 }
 
 // Execute prints the message content
-func (p PrintCommand) Execute() {
+func (p PrintCommand) Execute(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fmt.Println("打印消息", p.Content) // Print message
+	return nil
 }
 
 // SendEmail implements Command for sending emails
 type SendEmail struct {
-	To      string // Email recipient
-	Content string // Email content
+	To          string       // Email recipient
+	Subject     string       // Email subject
+	Content     string       // Email content
+	Attachments []Attachment // Optional attachments
 }
 
-// Execute sends an email
-func (s SendEmail) Execute() {
+// Execute sends an email through DefaultEmailSender
+func (s SendEmail) Execute(ctx context.Context) error {
+	if s.To == "" {
+		return Permanent(errors.New("send email: empty recipient"))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fmt.Println("发送邮件", s.To, s.Content) // Send email
+	if DefaultEmailSender == nil {
+		return nil
+	}
+	return DefaultEmailSender.SendEmail(ctx, &EmailMessage{
+		To:          []string{s.To},
+		Subject:     s.Subject,
+		Text:        s.Content,
+		Attachments: s.Attachments,
+	})
 }
 
 // SendTel implements Command for sending text messages
@@ -55,14 +88,34 @@ type SendTel struct {
 	Content string // Message content
 }
 
-// Execute sends a text message
-func (s SendTel) Execute() {
+// Execute sends a text message through DefaultSMSSender
+func (s SendTel) Execute(ctx context.Context) error {
+	if s.To == "" {
+		return Permanent(errors.New("send tel: empty recipient"))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	fmt.Println("发送短信", s.To, s.Content) // Send text message
+	if DefaultSMSSender == nil {
+		return nil
+	}
+	return DefaultSMSSender.SendSMS(ctx, s.To, s.Content)
 }
 
 // TaskQueue acts as the invoker that manages and executes commands
 type TaskQueue struct {
-	Queue []Command // List of commands to execute
+	Queue       []Command                     // List of commands to execute
+	History     *History                      // Optional history of executed undoable commands
+	DeadLetters *DeadLetterQueue              // Optional store for commands that exhausted their retries
+	DedupWindow time.Duration                 // Window in which commands with the same dedup key are dropped
+	Clock       Clock                         // Clock used for dedup and rate limits, real time when nil
+	Metrics     *Metrics                      // Optional counters and latency histograms
+	mu          sync.Mutex                    // Guards Queue, priorities and seen
+	retry       map[reflect.Type]RetryPolicy  // Retry policy per command type
+	priorities  []Priority                    // Priority of each queued command, parallel to Queue
+	seen        map[string]time.Time          // Dedup keys and when they were queued
+	limiters    map[reflect.Type]*RateLimiter // Rate limiter per command type
 }
 
 // NewTaskQueue creates a new task queue
@@ -70,20 +123,92 @@ func NewTaskQueue() *TaskQueue {
 	return &TaskQueue{}
 }
 
-// AddCommand adds a new command to the queue
+// AddCommand adds a new command to the queue with normal priority
 func (t *TaskQueue) AddCommand(command Command) {
-	t.Queue = append(t.Queue, command)
+	t.Enqueue(command)
+}
+
+// drain empties the queue and returns the commands in dispatch order
+func (t *TaskQueue) drain() []Command {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	queue := t.Queue
+	t.Queue = nil
+	t.priorities = nil
+	return queue
+}
+
+// Pending returns a snapshot of the queued commands and their priorities in dispatch order
+func (t *TaskQueue) Pending() ([]Command, []Priority) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	commands := append([]Command(nil), t.Queue...)
+	priorities := make([]Priority, len(commands))
+	copy(priorities, t.priorities)
+	return commands, priorities
+}
+
+// Command executes all commands in the queue serially and clears it
+// Failed commands do not stop the remaining ones; all errors are returned joined
+func (t *TaskQueue) Command(ctx context.Context) error {
+	queue := t.drain()
+
+	var errs []error
+	for _, command := range queue {
+		if err := t.execute(ctx, command, 0); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Record undoable commands so they can be rolled back later
+		if undoable, ok := command.(UndoableCommand); ok && t.History != nil {
+			t.History.Record(undoable)
+		}
+	}
+	return errors.Join(errs...)
 }
 
-// Command executes all commands in the queue
-func (t *TaskQueue) Command() {
-	for _, command := range t.Queue {
-		command.Execute()
+// Run drains the queue and executes the commands concurrently on the pool
+// Successful undoable commands are recorded in History in submission order
+// once the pool has finished, since their execution order is not defined.
+func (t *TaskQueue) Run(ctx context.Context, pool *Pool) *Report {
+	queue := t.drain()
+
+	// The pool's timeout is applied to each attempt instead of to all retries together
+	wrapped := make([]Command, len(queue))
+	for i, command := range queue {
+		wrapped[i] = retryingCommand{queue: t, command: command, timeout: pool.timeout}
 	}
+	report := (&Pool{workers: pool.workers}).Run(ctx, wrapped)
+	// Report the original commands rather than the retry wrappers
+	for i := range report.Results {
+		report.Results[i].Command = queue[i]
+		if undoable, ok := queue[i].(UndoableCommand); ok && t.History != nil && report.Results[i].Err == nil {
+			t.History.Record(undoable)
+		}
+	}
+	return report
+}
+
+// Undo rolls back the last n executed undoable commands
+func (t *TaskQueue) Undo(ctx context.Context, n int) (int, error) {
+	if t.History == nil {
+		return 0, nil
+	}
+	return t.History.Undo(ctx, n)
+}
+
+// Redo re-executes the last n undone commands
+func (t *TaskQueue) Redo(ctx context.Context, n int) (int, error) {
+	if t.History == nil {
+		return 0, nil
+	}
+	return t.History.Redo(ctx, n)
 }
 
 // Example usage of the Command Pattern
 func main() {
+	ctx := context.Background()
+
 	// Create the command invoker
 	queue := NewTaskQueue()
 
@@ -101,5 +226,169 @@ func main() {
 	})
 
 	// Execute all commands in the queue
-	queue.Command()
+	if err := queue.Command(ctx); err != nil {
+		fmt.Println(err)
+	}
+
+	// Build an editor on top of the task queue with undo/redo support
+	doc := &Document{}
+	editor := NewTaskQueue()
+	editor.History = NewHistory(10)
+	editor.AddCommand(&AppendText{Doc: doc, Text: "Hello"})
+	editor.AddCommand(&AppendText{Doc: doc, Text: ", "})
+	editor.AddCommand(&AppendText{Doc: doc, Text: "World"})
+	editor.Command(ctx)
+	fmt.Println(doc)
+
+	// Roll back the last two actions, then redo one of them
+	editor.Undo(ctx, 2)
+	fmt.Println(doc)
+	editor.Redo(ctx, 1)
+	fmt.Println(doc)
+
+	// Send notifications in parallel with bounded concurrency
+	notify := NewTaskQueue()
+	notify.AddCommand(&SendEmail{To: "a@qq.com", Content: "你好"})
+	notify.AddCommand(&SendTel{To: "11122223333", Content: "你好"})
+	notify.AddCommand(&SendEmail{Content: "没有收件人"}) // Missing recipient
+	notify.DeadLetters = NewDeadLetterQueue()
+	notify.SetRetryPolicy(SendEmail{}, RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    100 * time.Millisecond,
+		Jitter:      0.2,
+	})
+	report := notify.Run(ctx, NewPool(2, time.Second))
+	for _, result := range report.Failed() {
+		fmt.Println("失败", result.Err) // Failed
+	}
+	for _, letter := range notify.DeadLetters.List() {
+		fmt.Println("死信", letter.ID, letter.Attempts, letter.Err) // Dead letter
+	}
+	notify.DeadLetters.PurgeAll()
+
+	// Persist commands to a journal so they survive a restart
+	path := filepath.Join(os.TempDir(), "task_queue.journal")
+	defer os.Remove(path)
+	durable, err := OpenDurableQueue(path, NewRegistry())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	durable.Enqueue(&SendEmail{To: "b@qq.com", Content: "提醒"}) // Reminder
+	durable.Enqueue(&SendTel{To: "11122223333", Content: "提醒"})
+	durable.Close()
+
+	// Reopen the journal as if the process had restarted and replay pending commands
+	durable, err = OpenDurableQueue(path, NewRegistry())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer durable.Close()
+	fmt.Println("待执行", len(durable.Pending())) // Pending
+	if err := durable.Run(ctx); err != nil {
+		fmt.Println(err)
+	}
+
+	// Schedule a reminder SMS three days ahead and a daily one at 9:30 on a fake clock
+	clock := NewFakeClock(time.Date(2024, 1, 1, 8, 0, 0, 0, time.Local))
+	scheduler := NewScheduler(clock, NewTaskQueue())
+	reminder := scheduler.ScheduleAfter(&SendTel{To: "11122223333", Content: "三天后的提醒"}, 72*time.Hour) // Reminder in three days
+	daily, err := scheduler.ScheduleCron(&SendTel{To: "11122223333", Content: "每日提醒"}, "30 9 * * *")  // Daily reminder
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	next, _ := scheduler.NextRun(reminder)
+	fmt.Println("下次执行", next.Format(time.DateTime)) // Next run
+	next, _ = scheduler.NextRun(daily)
+	fmt.Println("下次执行", next.Format(time.DateTime))
+
+	ran := make(chan struct{}, 1)
+	scheduler.OnRun = func(command Command, err error) { ran <- struct{}{} }
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(runCtx)
+		close(done)
+	}()
+	clock.Advance(2 * time.Hour)
+	<-ran // The 9:30 reminder
+	cancel()
+	<-done
+
+	// Urgent commands jump the queue, duplicates are dropped and SMS are rate limited
+	burst := NewTaskQueue()
+	burst.DedupWindow = time.Minute
+	burst.SetRateLimit(SendTel{}, 10, 1)
+	burst.Enqueue(&SendEmail{To: "c@qq.com", Content: "周报"})                                // Weekly report
+	burst.Enqueue(&SendEmail{To: "c@qq.com", Content: "周报"})                                // Duplicate, dropped
+	burst.Enqueue(&SendTel{To: "11122223333", Content: "告警"}, WithPriority(PriorityUrgent)) // Alert
+	burst.Enqueue(&SendTel{To: "11122224444", Content: "告警"}, WithPriority(PriorityUrgent))
+	burst.Command(ctx)
+
+	// Queue an order as one atomic macro: the charge fails, so the reservation is rolled back
+	inventory := &Inventory{Stock: map[string]int{"book": 5}}
+	account := &Account{Balance: 1000}
+	orders := NewTaskQueue()
+	order, err := NewMacroCommand("order",
+		&ReserveStock{Inventory: inventory, Item: "book", Quantity: 2},
+		&Charge{Account: account, Amount: 5000},
+		&SendEmail{To: "d@qq.com", Content: "下单成功"}, // Order placed
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	orders.AddCommand(order)
+	if err := orders.Command(ctx); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println("库存", inventory.Stock["book"], "余额", account.Balance) // Stock, balance
+
+	// Deliver through the queue with retries and metrics; without DefaultEmailSender
+	// and DefaultSMSSender set to an SMTPMailer and HTTPSMSGateway, messages are only printed
+	delivery := NewTaskQueue()
+	delivery.Metrics = NewMetrics()
+	delivery.DeadLetters = NewDeadLetterQueue()
+	delivery.SetRetryPolicy(SendTel{}, RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond})
+	bill := &SendEmail{
+		To:          "e@qq.com",
+		Subject:     "月度账单",  // Monthly bill
+		Content:     "请查收附件", // Please see the attachment
+		Attachments: []Attachment{{Filename: "bill.txt", ContentType: "text/plain", Data: []byte("100")}},
+	}
+	delivery.AddCommand(bill)
+	delivery.AddCommand(&SendTel{To: "11122223333", Content: "账单已发送"}) // Bill sent
+	if err := delivery.Command(ctx); err != nil {
+		fmt.Println(err)
+	}
+	mimeMessage, err := BuildMIMEMessage(&EmailMessage{
+		From:        "robot@example.com",
+		To:          []string{bill.To},
+		Subject:     bill.Subject,
+		Text:        bill.Content,
+		Attachments: bill.Attachments,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("邮件大小", len(mimeMessage)) // Email size
+
+	// Inspect the queue over HTTP
+	delivery.AddCommand(&SendTel{To: "11122225555", Content: "待发送"}) // Pending
+	inspect := httptest.NewServer(NewInspectHandler(delivery))
+	defer inspect.Close()
+	for _, path := range []string{"/metrics", "/queue"} {
+		resp, err := http.Get(inspect.URL + path)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		fmt.Println(path, len(body), "bytes")
+	}
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"daily", "30 9 * * *", date(2024, 1, 1, 8, 0), date(2024, 1, 1, 9, 30)},
+		{"strictly after", "30 9 * * *", date(2024, 1, 1, 9, 30), date(2024, 1, 2, 9, 30)},
+		{"day of week only", "0 0 * * mon", date(2024, 1, 1, 0, 0), date(2024, 1, 8, 0, 0)},
+		{"dom or dow, dow first", "0 0 13 * fri", date(2024, 1, 1, 0, 0), date(2024, 1, 5, 0, 0)},
+		{"dom or dow, dom first", "0 0 13 * fri", date(2024, 1, 12, 0, 0), date(2024, 1, 13, 0, 0)},
+		{"7 is sunday", "0 12 * * 7", date(2024, 1, 1, 0, 0), date(2024, 1, 7, 12, 0)},
+		{"0 is sunday", "0 12 * * 0", date(2024, 1, 1, 0, 0), date(2024, 1, 7, 12, 0)},
+		{"skips short months", "0 0 31 * *", date(2024, 1, 31, 0, 0), date(2024, 3, 31, 0, 0)},
+		{"year rollover", "0 0 1 * *", date(2024, 12, 15, 0, 0), date(2025, 1, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *", date(2024, 3, 1, 0, 0), date(2028, 2, 29, 0, 0)},
+		{"steps and ranges", "*/15 9-10 * * mon-fri", date(2024, 1, 5, 10, 50), date(2024, 1, 8, 9, 0)},
+		{"month names", "0 0 1 jun *", date(2024, 1, 1, 0, 0), date(2024, 6, 1, 0, 0)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseCron(test.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", test.expr, err)
+			}
+			if got := schedule.Next(test.from); !got.Equal(test.want) {
+				t.Errorf("Next(%v) = %v, want %v", test.from, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Priority orders commands in the TaskQueue, higher runs first
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+	PriorityUrgent Priority = 2
+)
+
+// DedupKeyer is implemented by commands that know their own deduplication key
+type DedupKeyer interface {
+	DedupKey() string
+}
+
+// DedupKey identifies the same email to the same recipient
+func (s SendEmail) DedupKey() string {
+	return "SendEmail:" + s.To + ":" + s.Content
+}
+
+// DedupKey identifies the same text message to the same recipient
+func (s SendTel) DedupKey() string {
+	return "SendTel:" + s.To + ":" + s.Content
+}
+
+// enqueueOptions holds the settings applied by EnqueueOption
+type enqueueOptions struct {
+	priority Priority
+	dedupKey string
+}
+
+// EnqueueOption customizes how a command is added to the TaskQueue
+type EnqueueOption func(*enqueueOptions)
+
+// WithPriority sets the priority of the command
+func WithPriority(priority Priority) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.priority = priority
+	}
+}
+
+// WithDedupKey overrides the deduplication key of the command
+func WithDedupKey(key string) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.dedupKey = key
+	}
+}
+
+// Enqueue adds a command ordered by priority, FIFO among equal priorities
+// It returns false when a command with the same dedup key was queued within DedupWindow.
+func (t *TaskQueue) Enqueue(command Command, opts ...EnqueueOption) bool {
+	options := enqueueOptions{priority: PriorityNormal}
+	if keyer, ok := command.(DedupKeyer); ok {
+		options.dedupKey = keyer.DedupKey()
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.isDuplicate(options.dedupKey) {
+		return false
+	}
+	if t.Metrics != nil {
+		t.Metrics.Queued(command)
+	}
+
+	// Commands appended to Queue directly are treated as normal priority
+	for len(t.priorities) < len(t.Queue) {
+		t.priorities = append(t.priorities, PriorityNormal)
+	}
+	index := len(t.Queue)
+	for index > 0 && t.priorities[index-1] < options.priority {
+		index--
+	}
+	t.Queue = append(t.Queue, nil)
+	copy(t.Queue[index+1:], t.Queue[index:])
+	t.Queue[index] = command
+	t.priorities = append(t.priorities, 0)
+	copy(t.priorities[index+1:], t.priorities[index:])
+	t.priorities[index] = options.priority
+	return true
+}
+
+// isDuplicate reports whether key was seen within the dedup window and records it otherwise
+// The caller must hold t.mu
+func (t *TaskQueue) isDuplicate(key string) bool {
+	if key == "" || t.DedupWindow <= 0 {
+		return false
+	}
+	now := t.clock().Now()
+	if t.seen == nil {
+		t.seen = map[string]time.Time{}
+	}
+	// Forget keys whose window has passed
+	for k, at := range t.seen {
+		if now.Sub(at) >= t.DedupWindow {
+			delete(t.seen, k)
+		}
+	}
+	if _, ok := t.seen[key]; ok {
+		return true
+	}
+	t.seen[key] = now
+	return false
+}
+
+// clock returns the queue's clock, defaulting to real time
+func (t *TaskQueue) clock() Clock {
+	if t.Clock == nil {
+		return RealClock{}
+	}
+	return t.Clock
+}
+
+// SetRateLimit limits commands of the same type as prototype to rate per second
+// with bursts of up to burst commands
+func (t *TaskQueue) SetRateLimit(prototype Command, rate float64, burst int) error {
+	limiter, err := NewRateLimiter(t.clock(), rate, burst)
+	if err != nil {
+		return err
+	}
+	if t.limiters == nil {
+		t.limiters = map[reflect.Type]*RateLimiter{}
+	}
+	t.limiters[commandType(prototype)] = limiter
+	return nil
+}
+
+// wait blocks until the command's rate limiter allows it to run
+func (t *TaskQueue) wait(ctx context.Context, command Command) error {
+	limiter, ok := t.limiters[commandType(command)]
+	if !ok {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
+
+// RateLimiter is a token bucket shared by the commands of one type
+type RateLimiter struct {
+	mu     sync.Mutex
+	clock  Clock
+	rate   float64   // Tokens added per second
+	burst  float64   // Maximum number of tokens
+	tokens float64   // Currently available tokens
+	last   time.Time // Time tokens were last refilled
+}
+
+// NewRateLimiter creates a full token bucket
+// The rate must be positive and finite; remove the limiter instead of disabling it.
+func NewRateLimiter(clock Clock, rate float64, burst int) (*RateLimiter, error) {
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return nil, fmt.Errorf("rate limiter: rate %v must be positive and finite", rate)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		clock:  clock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}, nil
+}
+
+// reserve takes a token if one is available, otherwise returns how long to wait
+func (r *RateLimiter) reserve() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+	if r.tokens >= 1 {
+		r.tokens--
+		return 0
+	}
+	return time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+}
+
+// Wait blocks until a token is available or ctx is cancelled
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := r.reserve()
+		if delay <= 0 {
+			return nil
+		}
+		timer := r.clock.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// queuedNames returns the names of the queued noopCommands in dispatch order
+func queuedNames(t *TaskQueue) []string {
+	commands, _ := t.Pending()
+	names := make([]string, len(commands))
+	for i, command := range commands {
+		names[i] = command.(noopCommand).name
+	}
+	return names
+}
+
+func TestEnqueuePriorityOrder(t *testing.T) {
+	queue := NewTaskQueue()
+	queue.Queue = append(queue.Queue, noopCommand{"direct"}) // Treated as normal priority
+	queue.Enqueue(noopCommand{"low"}, WithPriority(PriorityLow))
+	queue.Enqueue(noopCommand{"normal"})
+	queue.Enqueue(noopCommand{"urgent"}, WithPriority(PriorityUrgent))
+	queue.Enqueue(noopCommand{"high1"}, WithPriority(PriorityHigh))
+	queue.Enqueue(noopCommand{"high2"}, WithPriority(PriorityHigh))
+
+	want := []string{"urgent", "high1", "high2", "direct", "normal", "low"}
+	got := queuedNames(queue)
+	if len(got) != len(want) {
+		t.Fatalf("queue = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("queue = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEnqueueDedupWindow(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	queue := NewTaskQueue()
+	queue.Clock = clock
+	queue.DedupWindow = time.Minute
+
+	email := SendEmail{To: "a@qq.com", Content: "周报"}
+	if !queue.Enqueue(email) {
+		t.Fatal("first email was dropped")
+	}
+	if queue.Enqueue(email) {
+		t.Fatal("duplicate email within the window was queued")
+	}
+	if !queue.Enqueue(SendEmail{To: "b@qq.com", Content: "周报"}) {
+		t.Fatal("email to another recipient was dropped")
+	}
+	if queue.Enqueue(noopCommand{"x"}, WithDedupKey(email.DedupKey())) {
+		t.Fatal("explicit dedup key was ignored")
+	}
+	clock.Advance(time.Minute)
+	if !queue.Enqueue(email) {
+		t.Fatal("email was dropped after the window passed")
+	}
+}
+
+func TestEnqueueWithoutDedupWindow(t *testing.T) {
+	queue := NewTaskQueue()
+	email := SendEmail{To: "a@qq.com", Content: "周报"}
+	if !queue.Enqueue(email) || !queue.Enqueue(email) {
+		t.Fatal("commands were deduplicated without a DedupWindow")
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	limiter, err := NewRateLimiter(clock, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if delay := limiter.reserve(); delay != 0 {
+			t.Fatalf("burst token %d: delay %v", i, delay)
+		}
+	}
+	if delay := limiter.reserve(); delay != 500*time.Millisecond {
+		t.Fatalf("empty bucket delay = %v, want 500ms", delay)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if delay := limiter.reserve(); delay != 0 {
+		t.Fatalf("delay after refill = %v, want 0", delay)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	limiter, _ := NewRateLimiter(clock, 1, 1)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- limiter.Wait(context.Background()) }()
+	select {
+	case <-done:
+		t.Fatal("Wait returned without a token")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait on an empty bucket with a cancelled context = %v", err)
+	}
+}
+
+func TestRateLimiterRejectsNonPositiveRates(t *testing.T) {
+	queue := NewTaskQueue()
+	for _, rate := range []float64{0, -1} {
+		if err := queue.SetRateLimit(SendTel{}, rate, 1); err == nil {
+			t.Fatalf("SetRateLimit(%v) succeeded, want an error", rate)
+		}
+	}
+	if len(queue.limiters) != 0 {
+		t.Fatal("a rejected rate limit was installed")
+	}
+}
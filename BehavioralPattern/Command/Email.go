@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string // File name shown to the recipient
+	ContentType string // MIME type, application/octet-stream when empty
+	Data        []byte // File content
+}
+
+// EmailMessage is a complete email ready to be delivered
+type EmailMessage struct {
+	From        string
+	To          []string
+	Subject     string
+	Text        string       // Plain-text body
+	HTML        string       // Optional HTML alternative of the body
+	Attachments []Attachment // Optional attachments
+}
+
+// EmailSender delivers email messages
+type EmailSender interface {
+	SendEmail(ctx context.Context, msg *EmailMessage) error
+}
+
+// DefaultEmailSender is used by SendEmail commands; when nil, emails are only printed
+var DefaultEmailSender EmailSender
+
+// SMTPMailer delivers emails through an SMTP server using net/smtp
+type SMTPMailer struct {
+	Addr      string      // Server address as host:port
+	Username  string      // Optional username for AUTH PLAIN
+	Password  string      // Optional password for AUTH PLAIN
+	From      string      // Default sender address
+	TLSConfig *tls.Config // TLS settings for STARTTLS, derived from Addr when nil
+	Timeout   time.Duration
+
+	// RequireTLS refuses to send when the server does not offer STARTTLS, so an
+	// attacker stripping it cannot downgrade the session to plaintext.
+	// Setting TLSConfig implies RequireTLS.
+	RequireTLS bool
+}
+
+// SendEmail connects to the server, upgrades with STARTTLS when offered,
+// authenticates when credentials are set and delivers the message
+func (m *SMTPMailer) SendEmail(ctx context.Context, msg *EmailMessage) error {
+	if msg.From == "" {
+		withFrom := *msg
+		withFrom.From = m.From
+		msg = &withFrom
+	}
+	data, err := BuildMIMEMessage(msg)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+
+	dialer := net.Dialer{Timeout: m.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Abort the conversation if the context is cancelled midway
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := m.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return fmt.Errorf("smtp: starttls: %w", err)
+		}
+	} else if m.RequireTLS || m.TLSConfig != nil {
+		return fmt.Errorf("smtp: server does not offer STARTTLS and TLS is required")
+	}
+	if m.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp: server does not support AUTH")
+		}
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, host)); err != nil {
+			return fmt.Errorf("smtp: auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(msg.From); err != nil {
+		return fmt.Errorf("smtp: mail from: %w", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp: rcpt to %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("smtp: data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: data: %w", err)
+	}
+	return client.Quit()
+}
+
+// BuildMIMEMessage renders an email as a MIME message
+// Bodies are base64 encoded so non-ASCII text survives any relay. An HTML body
+// becomes a multipart/alternative part and attachments a multipart/mixed wrapper.
+func BuildMIMEMessage(msg *EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	header := textproto.MIMEHeader{}
+	header.Set("From", msg.From)
+	header.Set("To", strings.Join(msg.To, ", "))
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header.Set("Date", time.Now().Format(time.RFC1123Z))
+	header.Set("Message-ID", messageID(msg.From))
+	header.Set("MIME-Version", "1.0")
+
+	// The HTML alternative needs its boundary before the body header is written
+	alternative := ""
+	if msg.HTML != "" {
+		alternative = multipart.NewWriter(nil).Boundary()
+	}
+
+	if len(msg.Attachments) == 0 {
+		writeHeader(&buf, mergeHeader(header, bodyHeader(alternative)))
+		if err := writeBody(&buf, msg, alternative); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header.Set("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	writeHeader(&buf, header)
+
+	part, err := mixed.CreatePart(bodyHeader(alternative))
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBody(part, msg, alternative); err != nil {
+		return nil, err
+	}
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, attachment.Data)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bodyHeader returns the headers of the body part, which is
+// multipart/alternative when an alternative boundary is given
+func bodyHeader(alternative string) textproto.MIMEHeader {
+	if alternative == "" {
+		return textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+		}
+	}
+	return textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + alternative},
+	}
+}
+
+// writeBody writes the plain text body, or both alternatives when a boundary is given
+func writeBody(w io.Writer, msg *EmailMessage, boundary string) error {
+	if boundary == "" {
+		writeBase64(w, []byte(msg.Text))
+		return nil
+	}
+	alternative := multipart.NewWriter(w)
+	if err := alternative.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, body := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		part, err := alternative.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {body.contentType},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return err
+		}
+		writeBase64(part, []byte(body.content))
+	}
+	return alternative.Close()
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+// writeHeader writes MIME headers followed by the blank separator line
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	for _, key := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if value := header.Get(key); value != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+// mergeHeader returns a copy of base with the values of extra added
+func mergeHeader(base, extra textproto.MIMEHeader) textproto.MIMEHeader {
+	merged := textproto.MIMEHeader{}
+	for key, values := range base {
+		merged[key] = values
+	}
+	for key, values := range extra {
+		merged[key] = values
+	}
+	return merged
+}
+
+// messageID generates a unique Message-ID in the sender's domain
+func messageID(from string) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok {
+		domain = strings.TrimSuffix(d, ">")
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), randomHex(8), domain)
+}
+
+// randomHex returns n random bytes as a hex string
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// newFakeSMTP starts a fake SMTP server for the duration of the test
+func newFakeSMTP(t *testing.T, startTLS bool) *FakeSMTPServer {
+	t.Helper()
+	server, err := NewFakeSMTPServer(startTLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// readPart decodes the body of a base64 encoded MIME part
+func readPart(t *testing.T, part *multipart.Part) string {
+	t.Helper()
+	data, err := io.ReadAll(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	if err != nil {
+		t.Fatalf("decode base64: %v", err)
+	}
+	return string(decoded)
+}
+
+func TestSMTPMailerDeliversOverSTARTTLSWithAuth(t *testing.T) {
+	server := newFakeSMTP(t, true)
+	server.Username, server.Password = "robot", "secret"
+	mailer := &SMTPMailer{
+		Addr:      server.Addr(),
+		Username:  "robot",
+		Password:  "secret",
+		From:      "robot@example.com",
+		TLSConfig: server.ClientTLSConfig(),
+	}
+
+	err := mailer.SendEmail(context.Background(), &EmailMessage{
+		To:          []string{"a@example.com", "b@example.com"},
+		Subject:     "月度账单",
+		Text:        "请查收附件",
+		HTML:        "<p>请查收附件</p>",
+		Attachments: []Attachment{{Filename: "bill.txt", ContentType: "text/plain", Data: []byte("100")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("messages = %d, want 1", len(messages))
+	}
+	got := messages[0]
+	if got.From != "robot@example.com" || strings.Join(got.To, ",") != "a@example.com,b@example.com" {
+		t.Errorf("envelope = %s -> %v", got.From, got.To)
+	}
+	if !got.TLS || got.User != "robot" {
+		t.Errorf("TLS = %v, user = %q, want an authenticated TLS session", got.TLS, got.User)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(got.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "月度账单" {
+		t.Errorf("subject = %q, %v", subject, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("content type = %q, %v, want multipart/mixed", mediaType, err)
+	}
+	mixed := multipart.NewReader(msg.Body, params["boundary"])
+
+	body, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, _ = mime.ParseMediaType(body.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("body type = %q, want multipart/alternative", mediaType)
+	}
+	alternative := multipart.NewReader(body, params["boundary"])
+	for _, want := range []struct{ contentType, content string }{
+		{"text/plain", "请查收附件"},
+		{"text/html", "<p>请查收附件</p>"},
+	} {
+		part, err := alternative.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); mediaType != want.contentType {
+			t.Errorf("alternative type = %q, want %q", mediaType, want.contentType)
+		}
+		if content := readPart(t, part); content != want.content {
+			t.Errorf("%s body = %q, want %q", want.contentType, content, want.content)
+		}
+	}
+
+	attachment, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName() != "bill.txt" {
+		t.Errorf("attachment name = %q, want bill.txt", attachment.FileName())
+	}
+	if content := readPart(t, attachment); content != "100" {
+		t.Errorf("attachment = %q, want 100", content)
+	}
+	if _, err := mixed.NextPart(); err != io.EOF {
+		t.Errorf("extra part after the attachment: %v", err)
+	}
+}
+
+func TestSMTPMailerRejectsWrongPassword(t *testing.T) {
+	server := newFakeSMTP(t, true)
+	server.Username, server.Password = "robot", "secret"
+	mailer := &SMTPMailer{
+		Addr:      server.Addr(),
+		Username:  "robot",
+		Password:  "wrong",
+		From:      "robot@example.com",
+		TLSConfig: server.ClientTLSConfig(),
+	}
+	err := mailer.SendEmail(context.Background(), &EmailMessage{To: []string{"a@example.com"}, Text: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "auth") {
+		t.Fatalf("err = %v, want an auth error", err)
+	}
+	if len(server.Messages()) != 0 {
+		t.Fatal("message delivered despite failed authentication")
+	}
+}
+
+func TestSMTPMailerRefusesPlaintextWhenTLSIsRequired(t *testing.T) {
+	// The server does not advertise STARTTLS, as if it had been stripped
+	server := newFakeSMTP(t, false)
+	for name, mailer := range map[string]*SMTPMailer{
+		"RequireTLS": {Addr: server.Addr(), From: "robot@example.com", RequireTLS: true},
+		"TLSConfig":  {Addr: server.Addr(), From: "robot@example.com", TLSConfig: server.ClientTLSConfig()},
+	} {
+		err := mailer.SendEmail(context.Background(), &EmailMessage{To: []string{"a@example.com"}, Text: "hi"})
+		if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+			t.Errorf("%s: err = %v, want a STARTTLS error", name, err)
+		}
+	}
+	if len(server.Messages()) != 0 {
+		t.Fatal("message delivered in plaintext")
+	}
+}
+
+func TestSMTPMailerAllowsPlaintextWhenTLSIsOptional(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	mailer := &SMTPMailer{Addr: server.Addr(), From: "robot@example.com"}
+	if err := mailer.SendEmail(context.Background(), &EmailMessage{To: []string{"a@example.com"}, Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].TLS {
+		t.Fatalf("messages = %+v, want one plaintext message", messages)
+	}
+}
+
+func TestSendEmailCommandUsesDefaultSender(t *testing.T) {
+	server := newFakeSMTP(t, true)
+	DefaultEmailSender = &SMTPMailer{Addr: server.Addr(), From: "robot@example.com", TLSConfig: server.ClientTLSConfig()}
+	defer func() { DefaultEmailSender = nil }()
+
+	queue := NewTaskQueue()
+	queue.AddCommand(&SendEmail{To: "e@qq.com", Subject: "账单", Content: "100"})
+	if err := queue.Command(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "e@qq.com" {
+		t.Fatalf("messages = %+v, want one email to e@qq.com", messages)
+	}
+}
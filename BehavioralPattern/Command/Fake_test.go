@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReceivedEmail is a message accepted by FakeSMTPServer
+type ReceivedEmail struct {
+	From string   // Envelope sender
+	To   []string // Envelope recipients
+	Data []byte   // Raw MIME message
+	TLS  bool     // Whether the session was upgraded with STARTTLS
+	User string   // Authenticated user, empty when not authenticated
+}
+
+// FakeSMTPServer is an in-process SMTP server that records received messages
+// It supports EHLO, STARTTLS with a self-signed certificate, AUTH PLAIN,
+// MAIL, RCPT, DATA, RSET, NOOP and QUIT, which is enough for net/smtp.
+type FakeSMTPServer struct {
+	Username string // When set, AUTH PLAIN with these credentials is required
+	Password string
+
+	listener  net.Listener
+	tlsConfig *tls.Config    // Server TLS config, nil when STARTTLS is disabled
+	certPool  *x509.CertPool // Pool trusting the self-signed certificate
+	mu        sync.Mutex
+	messages  []ReceivedEmail
+	wg        sync.WaitGroup
+}
+
+// NewFakeSMTPServer starts a fake SMTP server on a random local port
+func NewFakeSMTPServer(startTLS bool) (*FakeSMTPServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &FakeSMTPServer{listener: listener}
+	if startTLS {
+		cert, pool, err := selfSignedCertificate()
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		s.certPool = pool
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on
+func (s *FakeSMTPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// ClientTLSConfig returns a client TLS config that trusts the server certificate
+func (s *FakeSMTPServer) ClientTLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.certPool, ServerName: "127.0.0.1"}
+}
+
+// Messages returns the messages received so far
+func (s *FakeSMTPServer) Messages() []ReceivedEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ReceivedEmail(nil), s.messages...)
+}
+
+// Close stops the server and waits for open sessions to end
+func (s *FakeSMTPServer) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// serve accepts connections until the listener is closed
+func (s *FakeSMTPServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.session(conn)
+		}()
+	}
+}
+
+// session handles one SMTP conversation
+func (s *FakeSMTPServer) session(conn net.Conn) {
+	defer func() { conn.Close() }()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	text := textproto.NewConn(conn)
+	reply := func(format string, args ...any) bool {
+		return text.PrintfLine(format, args...) == nil
+	}
+	var (
+		secured bool
+		user    string
+		current *ReceivedEmail
+	)
+	reply("220 fake.smtp ESMTP ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250-fake.smtp")
+			if s.tlsConfig != nil && !secured {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250-AUTH PLAIN")
+			reply("250 8BITMIME")
+		case "STARTTLS":
+			if s.tlsConfig == nil || secured {
+				reply("502 STARTTLS not available")
+				continue
+			}
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			secured = true
+			user = ""
+			current = nil
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			if !strings.EqualFold(mechanism, "PLAIN") {
+				reply("504 unrecognized authentication type")
+				continue
+			}
+			if initial == "" {
+				reply("334 ")
+				if initial, err = text.ReadLine(); err != nil {
+					return
+				}
+			}
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(decoded), "\x00")
+			if err != nil || len(parts) != 3 || parts[1] != s.Username || parts[2] != s.Password {
+				reply("535 authentication failed")
+				continue
+			}
+			user = parts[1]
+			reply("235 authentication successful")
+		case "MAIL":
+			if s.Username != "" && user == "" {
+				reply("530 authentication required")
+				continue
+			}
+			current = &ReceivedEmail{From: addressArg(arg), TLS: secured, User: user}
+			reply("250 OK")
+		case "RCPT":
+			if current == nil {
+				reply("503 need MAIL first")
+				continue
+			}
+			current.To = append(current.To, addressArg(arg))
+			reply("250 OK")
+		case "DATA":
+			if current == nil || len(current.To) == 0 {
+				reply("503 need RCPT first")
+				continue
+			}
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			current.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, *current)
+			s.mu.Unlock()
+			current = nil
+			reply("250 OK queued")
+		case "RSET":
+			current = nil
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// addressArg extracts the address from "FROM:<addr>" or "TO:<addr>"
+func addressArg(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
+
+// selfSignedCertificate creates a certificate for 127.0.0.1 and a pool that trusts it
+func selfSignedCertificate() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "fake.smtp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: parsed}, pool, nil
+}
+
+// ReceivedSMS is a message accepted by FakeSMSGateway
+type ReceivedSMS struct {
+	To            string
+	Content       string
+	Authorization string // Authorization header sent by the client
+}
+
+// FakeSMSGateway is an in-process HTTP SMS gateway that records received messages
+type FakeSMSGateway struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	messages []ReceivedSMS
+	failures int // Number of upcoming requests to reject with 503
+}
+
+// NewFakeSMSGateway starts a fake gateway on a random local port
+func NewFakeSMSGateway() *FakeSMSGateway {
+	g := &FakeSMSGateway{}
+	g.server = httptest.NewServer(http.HandlerFunc(g.handle))
+	return g
+}
+
+// URL returns the endpoint messages should be posted to
+func (g *FakeSMSGateway) URL() string {
+	return g.server.URL + "/sms"
+}
+
+// FailNext makes the next n requests fail with 503 Service Unavailable
+func (g *FakeSMSGateway) FailNext(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = n
+}
+
+// Messages returns the messages received so far
+func (g *FakeSMSGateway) Messages() []ReceivedSMS {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]ReceivedSMS(nil), g.messages...)
+}
+
+// Close shuts the gateway down
+func (g *FakeSMSGateway) Close() {
+	g.server.Close()
+}
+
+// handle records a posted message
+func (g *FakeSMSGateway) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/sms" {
+		http.NotFound(w, r)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.failures > 0 {
+		g.failures--
+		http.Error(w, "gateway busy", http.StatusServiceUnavailable)
+		return
+	}
+	var req smsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.To == "" {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+	g.messages = append(g.messages, ReceivedSMS{
+		To:            req.To,
+		Content:       req.Content,
+		Authorization: r.Header.Get("Authorization"),
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
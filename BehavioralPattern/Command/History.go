@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// UndoableCommand is a Command whose effect can be reversed
+type UndoableCommand interface {
+	Command
+	Undo(ctx context.Context) error // Reverse the effect of Execute
+}
+
+// History keeps executed commands so they can be undone and redone
+// It is safe for concurrent use; Undo and Redo hold the lock while running commands.
+type History struct {
+	mu       sync.Mutex
+	undo     []UndoableCommand // Executed commands, most recent last
+	redo     []UndoableCommand // Undone commands, most recent last
+	maxDepth int               // Maximum number of commands kept for undo (0 means unlimited)
+}
+
+// NewHistory creates a history that keeps at most maxDepth commands
+func NewHistory(maxDepth int) *History {
+	return &History{
+		maxDepth: maxDepth,
+	}
+}
+
+// Record stores an executed command and clears the redo stack
+func (h *History) Record(command UndoableCommand) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.undo = append(h.undo, command)
+	if h.maxDepth > 0 && len(h.undo) > h.maxDepth {
+		// Drop the oldest commands once the depth is exceeded
+		h.undo = h.undo[len(h.undo)-h.maxDepth:]
+	}
+	h.redo = nil
+}
+
+// Undo reverses up to n of the most recently executed commands
+// It returns the number of commands actually undone and stops at the first failure
+func (h *History) Undo(ctx context.Context, n int) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	count := 0
+	for ; count < n && len(h.undo) > 0; count++ {
+		command := h.undo[len(h.undo)-1]
+		if err := command.Undo(ctx); err != nil {
+			return count, err
+		}
+		h.undo = h.undo[:len(h.undo)-1]
+		h.redo = append(h.redo, command)
+	}
+	return count, nil
+}
+
+// Redo re-executes up to n of the most recently undone commands
+// It returns the number of commands actually redone and stops at the first failure
+func (h *History) Redo(ctx context.Context, n int) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	count := 0
+	for ; count < n && len(h.redo) > 0; count++ {
+		command := h.redo[len(h.redo)-1]
+		if err := command.Execute(ctx); err != nil {
+			return count, err
+		}
+		h.redo = h.redo[:len(h.redo)-1]
+		h.undo = append(h.undo, command)
+	}
+	return count, nil
+}
+
+// CanUndo reports whether there is a command to undo
+func (h *History) CanUndo() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.undo) > 0
+}
+
+// CanRedo reports whether there is a command to redo
+func (h *History) CanRedo() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.redo) > 0
+}
+
+// Document is a simple text buffer used as the receiver of editor commands
+type Document struct {
+	text strings.Builder
+}
+
+// String returns the current document content
+func (d *Document) String() string {
+	return d.text.String()
+}
+
+// AppendText implements UndoableCommand for appending text to a document
+type AppendText struct {
+	Doc  *Document // Document to edit
+	Text string    // Text to append
+}
+
+// Execute appends the text to the document
+func (a *AppendText) Execute(ctx context.Context) error {
+	a.Doc.text.WriteString(a.Text)
+	fmt.Println("追加文本", a.Text) // Append text
+	return nil
+}
+
+// Undo removes the appended text from the end of the document
+func (a *AppendText) Undo(ctx context.Context) error {
+	content := a.Doc.text.String()
+	if !strings.HasSuffix(content, a.Text) {
+		return fmt.Errorf("undo append %q: document was modified", a.Text)
+	}
+	a.Doc.text.Reset()
+	a.Doc.text.WriteString(strings.TrimSuffix(content, a.Text))
+	fmt.Println("撤销追加", a.Text) // Undo append
+	return nil
+}
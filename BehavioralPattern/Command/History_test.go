@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// appendAll executes an AppendText command for each text and records it
+func appendAll(t *testing.T, history *History, doc *Document, texts ...string) {
+	t.Helper()
+	for _, text := range texts {
+		command := &AppendText{Doc: doc, Text: text}
+		if err := command.Execute(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		history.Record(command)
+	}
+}
+
+func TestHistoryUndoRedo(t *testing.T) {
+	ctx := context.Background()
+	doc := &Document{}
+	history := NewHistory(0)
+	appendAll(t, history, doc, "a", "b", "c")
+
+	if n, err := history.Undo(ctx, 2); n != 2 || err != nil {
+		t.Fatalf("Undo = %d, %v", n, err)
+	}
+	if doc.String() != "a" {
+		t.Fatalf("after undo doc = %q, want %q", doc, "a")
+	}
+	if n, err := history.Redo(ctx, 1); n != 1 || err != nil {
+		t.Fatalf("Redo = %d, %v", n, err)
+	}
+	if doc.String() != "ab" {
+		t.Fatalf("after redo doc = %q, want %q", doc, "ab")
+	}
+
+	// Undoing more than was recorded stops at the start
+	if n, _ := history.Undo(ctx, 10); n != 2 || doc.String() != "" {
+		t.Fatalf("Undo(10) = %d, doc %q", n, doc)
+	}
+	if history.CanUndo() {
+		t.Fatal("CanUndo after undoing everything")
+	}
+}
+
+func TestHistoryRecordClearsRedo(t *testing.T) {
+	doc := &Document{}
+	history := NewHistory(0)
+	appendAll(t, history, doc, "a", "b")
+	history.Undo(context.Background(), 1)
+	appendAll(t, history, doc, "c")
+	if history.CanRedo() {
+		t.Fatal("recording a new command should clear the redo stack")
+	}
+	if doc.String() != "ac" {
+		t.Fatalf("doc = %q, want %q", doc, "ac")
+	}
+}
+
+func TestHistoryMaxDepth(t *testing.T) {
+	doc := &Document{}
+	history := NewHistory(2)
+	appendAll(t, history, doc, "a", "b", "c")
+	if n, _ := history.Undo(context.Background(), 3); n != 2 {
+		t.Fatalf("undid %d commands, want 2", n)
+	}
+	if doc.String() != "a" {
+		t.Fatalf("doc = %q, want the evicted command kept: %q", doc, "a")
+	}
+}
+
+func TestHistoryUndoStopsAtFailure(t *testing.T) {
+	doc := &Document{}
+	history := NewHistory(0)
+	appendAll(t, history, doc, "a", "b")
+	doc.text.WriteString("x") // Modified outside the history
+	n, err := history.Undo(context.Background(), 2)
+	if n != 0 || err == nil {
+		t.Fatalf("Undo = %d, %v, want 0 and an error", n, err)
+	}
+	if !history.CanUndo() {
+		t.Fatal("a failed undo should stay on the undo stack")
+	}
+}
+
+func TestRunRecordsHistory(t *testing.T) {
+	ctx := context.Background()
+	doc := &Document{}
+	queue := NewTaskQueue()
+	queue.History = NewHistory(0)
+	queue.AddCommand(&AppendText{Doc: doc, Text: "a"})
+	queue.AddCommand(noopCommand{})
+	queue.Run(ctx, NewPool(1, time.Second))
+	queue.AddCommand(&AppendText{Doc: doc, Text: "b"})
+	queue.Run(ctx, NewPool(1, time.Second))
+
+	if n, err := queue.Undo(ctx, 2); n != 2 || err != nil {
+		t.Fatalf("Undo = %d, %v", n, err)
+	}
+	if doc.String() != "" {
+		t.Fatalf("doc = %q, want empty", doc)
+	}
+}
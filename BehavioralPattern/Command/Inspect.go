@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Summarizer is implemented by commands that describe themselves for inspection
+// A summary should identify the command without exposing its payload.
+type Summarizer interface {
+	Summary() string
+}
+
+// Summary describes the print without its content
+func (p PrintCommand) Summary() string {
+	return fmt.Sprintf("%d bytes", len(p.Content))
+}
+
+// Summary names the recipient and subject but not the body or attachments
+func (s SendEmail) Summary() string {
+	summary := fmt.Sprintf("to %s: %q", s.To, s.Subject)
+	if len(s.Attachments) > 0 {
+		summary += fmt.Sprintf(" (%d attachments)", len(s.Attachments))
+	}
+	return summary
+}
+
+// Summary names the recipient but not the message
+func (s SendTel) Summary() string {
+	return "to " + s.To
+}
+
+// Summary names the macro and its number of steps
+func (m *MacroCommand) Summary() string {
+	return fmt.Sprintf("%s (%d steps)", m.Name, len(m.Steps))
+}
+
+// summarize returns the summary of a command, empty when it has none
+func summarize(command Command) string {
+	if summarizer, ok := command.(Summarizer); ok {
+		return summarizer.Summary()
+	}
+	return ""
+}
+
+// pendingJSON describes a queued command in the inspection endpoint
+type pendingJSON struct {
+	Type     string   `json:"type"`
+	Priority Priority `json:"priority"`
+	Summary  string   `json:"summary,omitempty"`
+}
+
+// deadLetterJSON describes a dead letter in the inspection endpoint
+type deadLetterJSON struct {
+	ID       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+	Summary  string    `json:"summary,omitempty"`
+}
+
+// queueJSON is the body returned by the queue inspection endpoint
+type queueJSON struct {
+	Pending     []pendingJSON    `json:"pending"`
+	DeadLetters []deadLetterJSON `json:"dead_letters"`
+}
+
+// NewInspectHandler serves the queue's metrics and contents over HTTP
+// Commands are listed by type and Summarizer summary, never with their payload.
+//
+//	GET /metrics  Prometheus text exposition of t.Metrics
+//	GET /queue    JSON list of pending and dead-lettered commands
+func NewInspectHandler(t *TaskQueue) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if t.Metrics == nil {
+			http.Error(w, "metrics are not enabled", http.StatusNotFound)
+			return
+		}
+		var buf bytes.Buffer
+		if err := t.Metrics.WritePrometheus(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+	mux.HandleFunc("/queue", func(w http.ResponseWriter, r *http.Request) {
+		body := queueJSON{
+			Pending:     []pendingJSON{},
+			DeadLetters: []deadLetterJSON{},
+		}
+		commands, priorities := t.Pending()
+		for i, command := range commands {
+			body.Pending = append(body.Pending, pendingJSON{
+				Type:     commandType(command).Name(),
+				Priority: priorities[i],
+				Summary:  summarize(command),
+			})
+		}
+		if t.DeadLetters != nil {
+			for _, letter := range t.DeadLetters.List() {
+				body.DeadLetters = append(body.DeadLetters, deadLetterJSON{
+					ID:       letter.ID,
+					Type:     commandType(letter.Command).Name(),
+					Attempts: letter.Attempts,
+					Error:    letter.Err.Error(),
+					FailedAt: letter.FailedAt,
+					Summary:  summarize(letter.Command),
+				})
+			}
+		}
+		// Encode first so a failure is reported instead of an empty 200
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(buf.Bytes())
+	})
+	return mux
+}
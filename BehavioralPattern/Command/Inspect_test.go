@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// funcCommand holds a func field, which encoding/json cannot encode
+type funcCommand struct {
+	Run func()
+}
+
+func (funcCommand) Execute(ctx context.Context) error {
+	return nil
+}
+
+func get(t *testing.T, handler http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+func TestInspectQueue(t *testing.T) {
+	queue := NewTaskQueue()
+	queue.DeadLetters = NewDeadLetterQueue()
+	queue.Enqueue(SendEmail{
+		To:          "a@qq.com",
+		Subject:     "账单",
+		Content:     "secret body",
+		Attachments: []Attachment{{Filename: "bill.txt", Data: []byte("secret attachment")}},
+	}, WithPriority(PriorityHigh))
+	queue.Enqueue(funcCommand{Run: func() {}})
+	queue.DeadLetters.Add(SendTel{To: "11122223333", Content: "secret text"}, errors.New("gateway down"), 3)
+
+	resp := get(t, NewInspectHandler(queue), "/queue")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", resp.Code, resp.Body)
+	}
+	if strings.Contains(resp.Body.String(), "secret") {
+		t.Fatalf("body exposes command payloads: %s", resp.Body)
+	}
+	var body queueJSON
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Pending) != 2 || len(body.DeadLetters) != 1 {
+		t.Fatalf("body = %+v", body)
+	}
+	email := body.Pending[0]
+	if email.Type != "SendEmail" || email.Priority != PriorityHigh || email.Summary != `to a@qq.com: "账单" (1 attachments)` {
+		t.Fatalf("pending[0] = %+v", email)
+	}
+	if body.Pending[1].Type != "funcCommand" {
+		t.Fatalf("pending[1] = %+v", body.Pending[1])
+	}
+	letter := body.DeadLetters[0]
+	if letter.Type != "SendTel" || letter.Attempts != 3 || letter.Error != "gateway down" || letter.Summary != "to 11122223333" {
+		t.Fatalf("dead letter = %+v", letter)
+	}
+}
+
+func TestInspectMetrics(t *testing.T) {
+	queue := NewTaskQueue()
+	if resp := get(t, NewInspectHandler(queue), "/metrics"); resp.Code != http.StatusNotFound {
+		t.Fatalf("status without metrics = %d, want 404", resp.Code)
+	}
+	queue.Metrics = NewMetrics()
+	queue.Enqueue(SendTel{To: "1"})
+	resp := get(t, NewInspectHandler(queue), "/metrics")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), `task_queue_queued_total{type="SendTel"} 1`) {
+		t.Fatalf("body = %s", resp.Body)
+	}
+}
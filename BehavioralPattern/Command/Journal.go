@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry maps command type names to Go types so commands can be serialized
+type Registry struct {
+	types map[string]reflect.Type // Type name -> command struct type
+	names map[reflect.Type]string // Command struct type -> type name
+}
+
+// NewRegistry creates a registry with the built-in commands already registered
+func NewRegistry() *Registry {
+	r := &Registry{
+		types: map[string]reflect.Type{},
+		names: map[reflect.Type]string{},
+	}
+	r.Register("PrintCommand", PrintCommand{})
+	r.Register("SendEmail", SendEmail{})
+	r.Register("SendTel", SendTel{})
+	return r
+}
+
+// Register associates a name with the type of the given command prototype
+// Both value and pointer prototypes register the same underlying struct type
+func (r *Registry) Register(name string, prototype Command) {
+	t := commandType(prototype)
+	r.types[name] = t
+	r.names[t] = name
+}
+
+// Encode returns the registered name and JSON payload of a command
+func (r *Registry) Encode(command Command) (string, json.RawMessage, error) {
+	t := commandType(command)
+	name, ok := r.names[t]
+	if !ok {
+		return "", nil, fmt.Errorf("registry: unregistered command type %s", t)
+	}
+	data, err := json.Marshal(command)
+	if err != nil {
+		return "", nil, fmt.Errorf("registry: encode %s: %w", name, err)
+	}
+	return name, data, nil
+}
+
+// Decode rebuilds a command from its registered name and JSON payload
+func (r *Registry) Decode(name string, data json.RawMessage) (Command, error) {
+	t, ok := r.types[name]
+	if !ok {
+		return nil, fmt.Errorf("registry: unknown command type %q", name)
+	}
+	value := reflect.New(t)
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return nil, fmt.Errorf("registry: decode %s: %w", name, err)
+	}
+	command, ok := value.Interface().(Command)
+	if !ok {
+		return nil, fmt.Errorf("registry: %s does not implement Command", name)
+	}
+	return command, nil
+}
+
+// Journal operations
+const (
+	opAdd  = "add"  // A command was enqueued
+	opDone = "done" // A command completed successfully
+)
+
+// record is one line of the journal
+type record struct {
+	Op   string          `json:"op"`
+	ID   uint64          `json:"id"`
+	Type string          `json:"type,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Entry is a pending command stored in the journal
+type Entry struct {
+	ID      uint64  // Journal identifier of the command
+	Command Command // Decoded command
+}
+
+// DurableQueue is a task queue persisted to an append-only journal file
+// Every line is "<crc32> <json>" and is fsynced before the call returns,
+// so pending commands survive a process crash and are replayed on restart.
+// A command is only marked done after it succeeds, giving at-least-once delivery.
+type DurableQueue struct {
+	mu       sync.Mutex
+	path     string           // Journal file path
+	file     *os.File         // Journal opened for appending
+	registry *Registry        // Registry used to (de)serialize commands
+	pending  map[uint64]Entry // Commands added but not yet done
+	nextID   uint64           // Identifier for the next enqueued command
+}
+
+// ErrCorruptJournal is returned when a damaged record is followed by valid data,
+// which a crash while appending cannot produce
+var ErrCorruptJournal = errors.New("journal: corrupt record")
+
+// OpenDurableQueue opens (or creates) a journal and replays its pending commands
+// A torn record at the end of the journal is truncated away so new records
+// are not appended to it.
+func OpenDurableQueue(path string, registry *Registry) (*DurableQueue, error) {
+	q := &DurableQueue{
+		path:     path,
+		registry: registry,
+		pending:  map[uint64]Entry{},
+		nextID:   1,
+	}
+	good, err := q.replay()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err == nil && info.Size() > good {
+		if err = file.Truncate(good); err == nil {
+			err = file.Sync()
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	q.file = file
+	return q, nil
+}
+
+// replay reads the journal, rebuilds the set of pending commands and returns
+// the offset just past the last valid record. Only the final record may be torn
+// or fail its checksum; damage anywhere else returns ErrCorruptJournal.
+func (q *DurableQueue) replay() (int64, error) {
+	file, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var good int64
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if line == "" {
+			return good, nil
+		}
+		rec, ok := record{}, false
+		if strings.HasSuffix(line, "\n") {
+			rec, ok = decodeRecord(strings.TrimSuffix(line, "\n"))
+		}
+		if !ok {
+			// A torn tail is expected after a crash, anything after it is not
+			if _, peek := reader.Peek(1); peek == io.EOF {
+				return good, nil
+			}
+			return 0, fmt.Errorf("%w at offset %d", ErrCorruptJournal, good)
+		}
+		good += int64(len(line))
+
+		if rec.ID >= q.nextID {
+			q.nextID = rec.ID + 1
+		}
+		switch rec.Op {
+		case opAdd:
+			command, err := q.registry.Decode(rec.Type, rec.Data)
+			if err != nil {
+				return 0, fmt.Errorf("journal: replay %d: %w", rec.ID, err)
+			}
+			q.pending[rec.ID] = Entry{ID: rec.ID, Command: command}
+		case opDone:
+			delete(q.pending, rec.ID)
+		}
+	}
+}
+
+// encodeRecord formats a record as a checksummed journal line
+func encodeRecord(rec record) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data)), nil
+}
+
+// decodeRecord parses a journal line and verifies its checksum
+func decodeRecord(line string) (record, bool) {
+	var rec record
+	sum, data, found := strings.Cut(line, " ")
+	if !found {
+		return rec, false
+	}
+	if fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(data))) != sum {
+		return rec, false
+	}
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return rec, false
+	}
+	return rec, true
+}
+
+// append writes a record to the journal and fsyncs it
+func (q *DurableQueue) append(rec record) error {
+	line, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := q.file.Write(line); err != nil {
+		return err
+	}
+	return q.file.Sync()
+}
+
+// Enqueue persists a command and returns its journal identifier
+func (q *DurableQueue) Enqueue(command Command) (uint64, error) {
+	name, data, err := q.registry.Encode(command)
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	id := q.nextID
+	if err := q.append(record{Op: opAdd, ID: id, Type: name, Data: data}); err != nil {
+		return 0, err
+	}
+	q.nextID++
+	q.pending[id] = Entry{ID: id, Command: command}
+	return id, nil
+}
+
+// Complete marks a command as done so it is not replayed again
+func (q *DurableQueue) Complete(id uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.pending[id]; !ok {
+		return fmt.Errorf("journal: command %d is not pending", id)
+	}
+	if err := q.append(record{Op: opDone, ID: id}); err != nil {
+		return err
+	}
+	delete(q.pending, id)
+	return nil
+}
+
+// Pending returns the commands not yet completed, oldest first
+func (q *DurableQueue) Pending() []Entry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries := make([]Entry, 0, len(q.pending))
+	for _, entry := range q.pending {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// Run executes the pending commands in order and marks successful ones done
+// Failed commands stay pending and are retried on the next Run or restart
+func (q *DurableQueue) Run(ctx context.Context) error {
+	var errs []error
+	for _, entry := range q.Pending() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := entry.Command.Execute(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("command %d: %w", entry.ID, err))
+			continue
+		}
+		if err := q.Complete(entry.ID); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Compact rewrites the journal so it only contains pending commands
+func (q *DurableQueue) Compact() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tmp := q.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	ids := make([]uint64, 0, len(q.pending))
+	for id := range q.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		name, data, err := q.registry.Encode(q.pending[id].Command)
+		if err == nil {
+			var line []byte
+			line, err = encodeRecord(record{Op: opAdd, ID: id, Type: name, Data: data})
+			if err == nil {
+				_, err = file.Write(line)
+			}
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// Keep appending to the compacted journal
+	q.file.Close()
+	q.file = file
+	return nil
+}
+
+// Close closes the journal file
+func (q *DurableQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.file.Close()
+}
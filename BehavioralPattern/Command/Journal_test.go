@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openJournal opens the durable queue at path and fails the test on error
+func openJournal(t *testing.T, path string) *DurableQueue {
+	t.Helper()
+	q, err := OpenDurableQueue(path, NewRegistry())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return q
+}
+
+// printContent returns the content of a PrintCommand, decoded ones are pointers
+func printContent(command Command) string {
+	if p, ok := command.(*PrintCommand); ok {
+		return p.Content
+	}
+	return command.(PrintCommand).Content
+}
+
+// appendRaw appends bytes to the journal as a crash would leave them
+func appendRaw(t *testing.T, path, data string) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDurableQueueTruncatesTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	q := openJournal(t, path)
+	if _, err := q.Enqueue(PrintCommand{Content: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	appendRaw(t, path, `deadbeef {"op":"add","id":2,"ty`)
+
+	q = openJournal(t, path)
+	for _, content := range []string{"2", "3"} {
+		if _, err := q.Enqueue(PrintCommand{Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+
+	q = openJournal(t, path)
+	defer q.Close()
+	pending := q.Pending()
+	if len(pending) != 3 {
+		t.Fatalf("pending = %d, want 3", len(pending))
+	}
+	for i, want := range []string{"1", "2", "3"} {
+		if got := printContent(pending[i].Command); got != want {
+			t.Errorf("pending[%d] = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestDurableQueueTruncatesBadChecksumTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	q := openJournal(t, path)
+	q.Enqueue(PrintCommand{Content: "1"})
+	q.Close()
+
+	appendRaw(t, path, "00000000 {}\n")
+
+	q = openJournal(t, path)
+	q.Enqueue(PrintCommand{Content: "2"})
+	q.Close()
+
+	q = openJournal(t, path)
+	defer q.Close()
+	if got := len(q.Pending()); got != 2 {
+		t.Fatalf("pending = %d, want 2", got)
+	}
+}
+
+func TestDurableQueueRejectsCorruptionInTheMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	q := openJournal(t, path)
+	q.Enqueue(PrintCommand{Content: "1"})
+	q.Close()
+
+	appendRaw(t, path, "00000000 {}\n")
+	line, err := encodeRecord(record{Op: opDone, ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendRaw(t, path, string(line))
+
+	_, err = OpenDurableQueue(path, NewRegistry())
+	if !errors.Is(err, ErrCorruptJournal) {
+		t.Fatalf("err = %v, want ErrCorruptJournal", err)
+	}
+}
+
+func TestDurableQueueCompleteSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	q := openJournal(t, path)
+	id, _ := q.Enqueue(PrintCommand{Content: "1"})
+	q.Enqueue(PrintCommand{Content: "2"})
+	if err := q.Complete(id); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	q = openJournal(t, path)
+	defer q.Close()
+	pending := q.Pending()
+	if len(pending) != 1 || printContent(pending[0].Command) != "2" {
+		t.Fatalf("pending = %+v, want only command 2", pending)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MacroCommand runs a list of commands as a single unit
+// If a step fails, the steps already completed are undone in reverse order,
+// so the macro either takes full effect or none at all. Every step other than
+// the last must therefore be an UndoableCommand.
+type MacroCommand struct {
+	Name  string    // Name used in error messages
+	Steps []Command // Steps executed in order
+}
+
+// NewMacroCommand creates a macro from the given steps
+// It fails if a step before the last cannot be undone.
+func NewMacroCommand(name string, steps ...Command) (*MacroCommand, error) {
+	m := &MacroCommand{
+		Name:  name,
+		Steps: steps,
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// validate checks that every step that may need rolling back is undoable
+func (m *MacroCommand) validate() error {
+	for i := 0; i < len(m.Steps)-1; i++ {
+		if _, ok := m.Steps[i].(UndoableCommand); !ok {
+			return fmt.Errorf("macro %s: step %d (%T) cannot be undone", m.Name, i+1, m.Steps[i])
+		}
+	}
+	return nil
+}
+
+// Execute runs every step and rolls back the completed ones on failure
+// The steps are validated first, so an invalid macro has no side effects.
+func (m *MacroCommand) Execute(ctx context.Context) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	for i, step := range m.Steps {
+		if err := step.Execute(ctx); err != nil {
+			err = fmt.Errorf("macro %s: step %d: %w", m.Name, i+1, err)
+			if rollbackErr := m.rollback(m.Steps[:i]); rollbackErr != nil {
+				return errors.Join(err, rollbackErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Undo reverses every step of a macro that completed successfully
+func (m *MacroCommand) Undo(ctx context.Context) error {
+	return m.rollback(m.Steps)
+}
+
+// rollback undoes the given completed steps in reverse order
+// It keeps going after a failure so as much as possible is restored
+func (m *MacroCommand) rollback(completed []Command) error {
+	var errs []error
+	for i := len(completed) - 1; i >= 0; i-- {
+		undoable, ok := completed[i].(UndoableCommand)
+		if !ok {
+			errs = append(errs, fmt.Errorf("macro %s: step %d cannot be undone", m.Name, i+1))
+			continue
+		}
+		// Rollback must run even if the original context was cancelled
+		if err := undoable.Undo(context.Background()); err != nil {
+			errs = append(errs, fmt.Errorf("macro %s: undo step %d: %w", m.Name, i+1, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Inventory is the receiver for stock reservation commands
+type Inventory struct {
+	Stock map[string]int // Available quantity per item
+}
+
+// ReserveStock implements UndoableCommand for reserving items
+type ReserveStock struct {
+	Inventory *Inventory
+	Item      string // Item to reserve
+	Quantity  int    // Quantity to reserve
+}
+
+// Execute reserves the items if enough are in stock
+func (r *ReserveStock) Execute(ctx context.Context) error {
+	if r.Inventory.Stock[r.Item] < r.Quantity {
+		return fmt.Errorf("reserve %s: insufficient stock", r.Item)
+	}
+	r.Inventory.Stock[r.Item] -= r.Quantity
+	fmt.Println("预留库存", r.Item, r.Quantity) // Reserve stock
+	return nil
+}
+
+// Undo returns the reserved items to stock
+func (r *ReserveStock) Undo(ctx context.Context) error {
+	r.Inventory.Stock[r.Item] += r.Quantity
+	fmt.Println("释放库存", r.Item, r.Quantity) // Release stock
+	return nil
+}
+
+// Account is the receiver for payment commands
+type Account struct {
+	Balance int // Balance in cents
+}
+
+// Charge implements UndoableCommand for charging an account
+type Charge struct {
+	Account *Account
+	Amount  int // Amount in cents
+}
+
+// Execute charges the account if the balance allows it
+func (c *Charge) Execute(ctx context.Context) error {
+	if c.Account.Balance < c.Amount {
+		return fmt.Errorf("charge %d: insufficient balance", c.Amount)
+	}
+	c.Account.Balance -= c.Amount
+	fmt.Println("扣款", c.Amount) // Charge
+	return nil
+}
+
+// Undo refunds the charged amount
+func (c *Charge) Undo(ctx context.Context) error {
+	c.Account.Balance += c.Amount
+	fmt.Println("退款", c.Amount) // Refund
+	return nil
+}
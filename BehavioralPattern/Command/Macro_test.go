@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// step is an undoable command that logs its calls
+type step struct {
+	name    string
+	log     *[]string
+	err     error // Returned by Execute
+	undoErr error // Returned by Undo
+}
+
+func (s *step) Execute(ctx context.Context) error {
+	*s.log = append(*s.log, "do "+s.name)
+	return s.err
+}
+
+func (s *step) Undo(ctx context.Context) error {
+	*s.log = append(*s.log, "undo "+s.name)
+	return s.undoErr
+}
+
+func TestMacroRollsBackInReverseOrder(t *testing.T) {
+	var log []string
+	failure := errors.New("boom")
+	macro, err := NewMacroCommand("order",
+		&step{name: "a", log: &log},
+		&step{name: "b", log: &log},
+		&step{name: "c", log: &log, err: failure},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := macro.Execute(context.Background()); !errors.Is(err, failure) {
+		t.Fatalf("Execute = %v, want the step error", err)
+	}
+	want := "do a,do b,do c,undo b,undo a"
+	if got := strings.Join(log, ","); got != want {
+		t.Fatalf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestMacroJoinsRollbackErrors(t *testing.T) {
+	var log []string
+	failure := errors.New("boom")
+	undoA := errors.New("undo a failed")
+	undoB := errors.New("undo b failed")
+	macro, _ := NewMacroCommand("order",
+		&step{name: "a", log: &log, undoErr: undoA},
+		&step{name: "b", log: &log, undoErr: undoB},
+		&step{name: "c", log: &log, err: failure},
+	)
+	err := macro.Execute(context.Background())
+	for _, want := range []error{failure, undoA, undoB} {
+		if !errors.Is(err, want) {
+			t.Fatalf("Execute = %v, missing %v", err, want)
+		}
+	}
+	// Rollback keeps going after the first failed undo
+	if got := strings.Join(log, ","); got != "do a,do b,do c,undo b,undo a" {
+		t.Fatalf("calls = %s", got)
+	}
+}
+
+func TestMacroUndoAfterSuccess(t *testing.T) {
+	var log []string
+	macro, _ := NewMacroCommand("order",
+		&step{name: "a", log: &log},
+		&step{name: "b", log: &log},
+	)
+	ctx := context.Background()
+	if err := macro.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := macro.Undo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(log, ","); got != "do a,do b,undo b,undo a" {
+		t.Fatalf("calls = %s", got)
+	}
+}
+
+func TestMacroRejectsStepsThatCannotBeUndone(t *testing.T) {
+	var log []string
+	if _, err := NewMacroCommand("order", noopCommand{"x"}, &step{name: "a", log: &log}); err == nil {
+		t.Fatal("NewMacroCommand accepted a step that cannot be undone before the last")
+	}
+	// The last step never needs rolling back
+	if _, err := NewMacroCommand("order", &step{name: "a", log: &log}, noopCommand{"x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A macro built directly is checked before any step runs
+	macro := &MacroCommand{Name: "order", Steps: []Command{&step{name: "a", log: &log}, noopCommand{"x"}, &step{name: "b", log: &log}}}
+	if err := macro.Execute(context.Background()); err == nil {
+		t.Fatal("Execute ran an invalid macro")
+	}
+	if len(log) != 0 {
+		t.Fatalf("invalid macro had side effects: %v", log)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the latency histogram
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// typeMetrics holds the counters of one command type
+type typeMetrics struct {
+	queued    uint64
+	running   int64
+	succeeded uint64
+	failed    uint64
+	retried   uint64
+	buckets   []uint64 // Cumulative counts are computed when rendering
+	sum       float64  // Total execution time in seconds
+	count     uint64   // Number of observed executions
+}
+
+// Metrics collects counters and latency histograms per command type
+type Metrics struct {
+	Prefix string // Metric name prefix
+
+	mu     sync.Mutex
+	bounds []float64               // Histogram bucket upper bounds in seconds
+	byType map[string]*typeMetrics // Command type name -> metrics
+}
+
+// NewMetrics creates metrics using DefaultLatencyBuckets
+func NewMetrics() *Metrics {
+	return &Metrics{
+		bounds: DefaultLatencyBuckets,
+		byType: map[string]*typeMetrics{},
+		Prefix: "task_queue",
+	}
+}
+
+// get returns the metrics of a command type, creating them on first use
+// The caller must hold m.mu
+func (m *Metrics) get(command Command) *typeMetrics {
+	name := commandType(command).Name()
+	metrics, ok := m.byType[name]
+	if !ok {
+		metrics = &typeMetrics{buckets: make([]uint64, len(m.bounds))}
+		m.byType[name] = metrics
+	}
+	return metrics
+}
+
+// Queued counts a command added to the queue
+func (m *Metrics) Queued(command Command) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(command).queued++
+}
+
+// Started marks a command as running
+func (m *Metrics) Started(command Command) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(command).running++
+}
+
+// Retried counts a retry of a failed attempt
+func (m *Metrics) Retried(command Command) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(command).retried++
+}
+
+// Finished records the outcome and total latency of a command
+func (m *Metrics) Finished(command Command, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics := m.get(command)
+	metrics.running--
+	if err != nil {
+		metrics.failed++
+	} else {
+		metrics.succeeded++
+	}
+	seconds := duration.Seconds()
+	metrics.sum += seconds
+	metrics.count++
+	for i, bound := range m.bounds {
+		if seconds <= bound {
+			metrics.buckets[i]++
+			break
+		}
+	}
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.byType))
+	for name := range m.byType {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	counters := []struct {
+		name, help, kind string
+		value            func(*typeMetrics) string
+	}{
+		{"queued_total", "Commands added to the queue.", "counter", func(t *typeMetrics) string { return strconv.FormatUint(t.queued, 10) }},
+		{"running", "Commands currently executing.", "gauge", func(t *typeMetrics) string { return strconv.FormatInt(t.running, 10) }},
+		{"succeeded_total", "Commands that completed successfully.", "counter", func(t *typeMetrics) string { return strconv.FormatUint(t.succeeded, 10) }},
+		{"failed_total", "Commands that failed after all retries.", "counter", func(t *typeMetrics) string { return strconv.FormatUint(t.failed, 10) }},
+		{"retried_total", "Retries of failed attempts.", "counter", func(t *typeMetrics) string { return strconv.FormatUint(t.retried, 10) }},
+	}
+	for _, counter := range counters {
+		metric := m.Prefix + "_" + counter.name
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", metric, counter.help, metric, counter.kind)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s{type=%q} %s\n", metric, name, counter.value(m.byType[name]))
+		}
+	}
+
+	metric := m.Prefix + "_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s Command execution time including retries.\n# TYPE %s histogram\n", metric, metric)
+	for _, name := range names {
+		metrics := m.byType[name]
+		var cumulative uint64
+		for i, bound := range m.bounds {
+			cumulative += metrics.buckets[i]
+			fmt.Fprintf(&b, "%s_bucket{type=%q,le=%q} %d\n", metric, name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{type=%q,le=\"+Inf\"} %d\n", metric, name, metrics.count)
+		fmt.Fprintf(&b, "%s_sum{type=%q} %s\n", metric, name, strconv.FormatFloat(metrics.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "%s_count{type=%q} %d\n", metric, name, metrics.count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
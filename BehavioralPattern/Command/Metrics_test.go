@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	metrics := NewMetrics()
+	email := SendEmail{To: "a@qq.com"}
+	metrics.Queued(email)
+	metrics.Queued(email)
+	metrics.Started(email)
+	metrics.Retried(email)
+	metrics.Finished(email, 20*time.Millisecond, nil)
+	metrics.Started(email)
+	metrics.Finished(email, 3*time.Second, errors.New("boom"))
+	metrics.Queued(SendTel{To: "1"})
+
+	var b strings.Builder
+	if err := metrics.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		"# TYPE task_queue_queued_total counter",
+		`task_queue_queued_total{type="SendEmail"} 2`,
+		`task_queue_queued_total{type="SendTel"} 1`,
+		"# TYPE task_queue_running gauge",
+		`task_queue_running{type="SendEmail"} 0`,
+		`task_queue_succeeded_total{type="SendEmail"} 1`,
+		`task_queue_failed_total{type="SendEmail"} 1`,
+		`task_queue_retried_total{type="SendEmail"} 1`,
+		"# TYPE task_queue_duration_seconds histogram",
+		`task_queue_duration_seconds_bucket{type="SendEmail",le="0.01"} 0`,
+		`task_queue_duration_seconds_bucket{type="SendEmail",le="0.025"} 1`,
+		`task_queue_duration_seconds_bucket{type="SendEmail",le="2.5"} 1`,
+		`task_queue_duration_seconds_bucket{type="SendEmail",le="5"} 2`,
+		`task_queue_duration_seconds_bucket{type="SendEmail",le="+Inf"} 2`,
+		`task_queue_duration_seconds_sum{type="SendEmail"} 3.02`,
+		`task_queue_duration_seconds_count{type="SendEmail"} 2`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing line %q", line)
+		}
+	}
+	// Types are listed in a stable order
+	if strings.Index(out, `queued_total{type="SendEmail"}`) > strings.Index(out, `queued_total{type="SendTel"}`) {
+		t.Error("types are not sorted")
+	}
+}
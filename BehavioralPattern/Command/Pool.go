@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Result describes the outcome of a single command run by the pool
+type Result struct {
+	Command  Command       // Command that was executed
+	Err      error         // Error returned by the command, nil on success
+	Duration time.Duration // Time spent executing the command
+}
+
+// Report collects the results of a pool run in submission order
+type Report struct {
+	Results []Result
+}
+
+// Failed returns the results of commands that returned an error
+func (r *Report) Failed() []Result {
+	var failed []Result
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Pool executes commands concurrently with a bounded number of workers
+type Pool struct {
+	workers int           // Maximum number of commands running at once
+	timeout time.Duration // Per-command timeout (0 means no timeout)
+}
+
+// NewPool creates a worker pool with the given concurrency and per-command timeout
+func NewPool(workers int, timeout time.Duration) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		workers: workers,
+		timeout: timeout,
+	}
+}
+
+// Run executes all commands and waits for them to finish
+// Commands not yet started when ctx is cancelled are reported with ctx.Err()
+func (p *Pool) Run(ctx context.Context, commands []Command) *Report {
+	report := &Report{Results: make([]Result, len(commands))}
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				report.Results[index] = p.execute(ctx, commands[index])
+			}
+		}()
+	}
+
+	for index, command := range commands {
+		// Checked first because select picks randomly when a worker is also ready
+		if ctx.Err() == nil {
+			select {
+			case jobs <- index:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		// Mark the remaining commands as cancelled without running them
+		report.Results[index] = Result{Command: command, Err: ctx.Err()}
+	}
+	close(jobs)
+	wg.Wait()
+	return report
+}
+
+// execute runs a single command under the per-command timeout
+func (p *Pool) execute(ctx context.Context, command Command) Result {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := command.Execute(ctx)
+	return Result{
+		Command:  command,
+		Err:      err,
+		Duration: time.Since(start),
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// trackedCommand records how many commands run at once
+type trackedCommand struct {
+	running, peak, runs *atomic.Int32
+	delay              time.Duration
+}
+
+func (c trackedCommand) Execute(ctx context.Context) error {
+	c.runs.Add(1)
+	n := c.running.Add(1)
+	defer c.running.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(c.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func trackedCommands(n int, delay time.Duration) ([]Command, *atomic.Int32, *atomic.Int32) {
+	var running, peak, runs atomic.Int32
+	commands := make([]Command, n)
+	for i := range commands {
+		commands[i] = trackedCommand{running: &running, peak: &peak, runs: &runs, delay: delay}
+	}
+	return commands, &peak, &runs
+}
+
+func TestPoolBoundsConcurrency(t *testing.T) {
+	commands, peak, runs := trackedCommands(10, 10*time.Millisecond)
+	report := NewPool(3, 0).Run(context.Background(), commands)
+	if failed := report.Failed(); len(failed) > 0 {
+		t.Fatalf("unexpected failures: %v", failed[0].Err)
+	}
+	if got := runs.Load(); got != 10 {
+		t.Fatalf("ran %d commands, want 10", got)
+	}
+	if got := peak.Load(); got > 3 {
+		t.Fatalf("%d commands ran at once, want at most 3", got)
+	}
+	for i, result := range report.Results {
+		if result.Command != commands[i] {
+			t.Fatalf("result %d is not reported in submission order", i)
+		}
+	}
+}
+
+func TestPoolTimeoutPerCommand(t *testing.T) {
+	commands, _, _ := trackedCommands(2, time.Second)
+	start := time.Now()
+	report := NewPool(2, 20*time.Millisecond).Run(context.Background(), commands)
+	for _, result := range report.Results {
+		if !errors.Is(result.Err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want context.DeadlineExceeded", result.Err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("timeout not applied, took %v", elapsed)
+	}
+}
+
+func TestPoolCancelledContext(t *testing.T) {
+	commands, _, runs := trackedCommands(20, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 20; i++ {
+		report := NewPool(4, 0).Run(ctx, commands)
+		for _, result := range report.Results {
+			if !errors.Is(result.Err, context.Canceled) {
+				t.Fatalf("err = %v, want context.Canceled", result.Err)
+			}
+		}
+	}
+	if got := runs.Load(); got != 0 {
+		t.Fatalf("%d commands ran after cancellation", got)
+	}
+}
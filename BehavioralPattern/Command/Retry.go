@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how a failed command is retried
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first one
+	BaseDelay   time.Duration // Delay before the first retry
+	MaxDelay    time.Duration // Upper bound for the delay (0 means unbounded)
+	Jitter      float64       // Random fraction of the delay added or removed, between 0 and 1
+}
+
+// DefaultRetryPolicy runs a command once without retrying
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// Backoff returns the delay before the given retry (1 for the first retry)
+// The delay doubles with each retry and is spread by the jitter fraction,
+// never exceeding MaxDelay
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err so the command is dead-lettered without further retries
+// Use it for validation errors and other failures that retrying cannot fix.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err, or an error it wraps, was marked with Permanent
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// commandType returns the struct type of a command, ignoring pointers
+func commandType(command Command) reflect.Type {
+	t := reflect.TypeOf(command)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// DeadLetter is a command that exhausted its retries
+type DeadLetter struct {
+	ID       uint64    // Identifier used to requeue or purge the letter
+	Command  Command   // Command that failed
+	Err      error     // Last error returned by the command
+	Attempts int       // Number of attempts made
+	FailedAt time.Time // Time of the last failure
+}
+
+// DeadLetterQueue stores commands that failed permanently for inspection
+type DeadLetterQueue struct {
+	mu      sync.Mutex
+	letters []DeadLetter // Dead letters, oldest first
+	nextID  uint64       // Identifier for the next dead letter
+}
+
+// NewDeadLetterQueue creates an empty dead-letter queue
+func NewDeadLetterQueue() *DeadLetterQueue {
+	return &DeadLetterQueue{nextID: 1}
+}
+
+// Add stores a failed command and returns its identifier
+func (d *DeadLetterQueue) Add(command Command, err error, attempts int) uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := d.nextID
+	d.nextID++
+	d.letters = append(d.letters, DeadLetter{
+		ID:       id,
+		Command:  command,
+		Err:      err,
+		Attempts: attempts,
+		FailedAt: time.Now(),
+	})
+	return id
+}
+
+// List returns a copy of all dead letters
+func (d *DeadLetterQueue) List() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DeadLetter(nil), d.letters...)
+}
+
+// Len returns the number of dead letters
+func (d *DeadLetterQueue) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.letters)
+}
+
+// Take removes a dead letter and returns its command
+func (d *DeadLetterQueue) Take(id uint64) (Command, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, letter := range d.letters {
+		if letter.ID == id {
+			d.letters = append(d.letters[:i], d.letters[i+1:]...)
+			return letter.Command, nil
+		}
+	}
+	return nil, fmt.Errorf("dead letter %d not found", id)
+}
+
+// Purge removes a single dead letter
+func (d *DeadLetterQueue) Purge(id uint64) error {
+	_, err := d.Take(id)
+	return err
+}
+
+// PurgeAll removes every dead letter and returns how many were dropped
+func (d *DeadLetterQueue) PurgeAll() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	count := len(d.letters)
+	d.letters = nil
+	return count
+}
+
+// SetRetryPolicy configures retries for commands of the same type as prototype
+func (t *TaskQueue) SetRetryPolicy(prototype Command, policy RetryPolicy) {
+	if t.retry == nil {
+		t.retry = map[reflect.Type]RetryPolicy{}
+	}
+	t.retry[commandType(prototype)] = policy
+}
+
+// retryPolicy returns the policy configured for the command's type
+func (t *TaskQueue) retryPolicy(command Command) RetryPolicy {
+	if policy, ok := t.retry[commandType(command)]; ok {
+		return policy
+	}
+	return DefaultRetryPolicy
+}
+
+// Requeue moves a dead letter back onto the queue
+func (t *TaskQueue) Requeue(id uint64) error {
+	if t.DeadLetters == nil {
+		return fmt.Errorf("dead letter %d not found", id)
+	}
+	command, err := t.DeadLetters.Take(id)
+	if err != nil {
+		return err
+	}
+	// Requeued commands were already accepted once, so skip deduplication
+	t.Enqueue(command, WithDedupKey(""))
+	return nil
+}
+
+// execute runs a command with its retry policy
+// The timeout, when positive, applies to each attempt separately rather than to
+// the retries, backoff and rate-limit waits together.
+// A command that still fails after the last attempt, or fails permanently,
+// is moved to the dead-letter queue
+func (t *TaskQueue) execute(ctx context.Context, command Command, timeout time.Duration) (err error) {
+	policy := t.retryPolicy(command)
+	if t.Metrics != nil {
+		start := time.Now()
+		t.Metrics.Started(command)
+		defer func() {
+			t.Metrics.Finished(command, time.Since(start), err)
+		}()
+	}
+
+	attempts := 0
+	for {
+		attempts++
+		if err = t.wait(ctx, command); err != nil {
+			break
+		}
+		if err = attempt(ctx, command, timeout); err == nil {
+			return nil
+		}
+		if attempts >= policy.MaxAttempts || ctx.Err() != nil || IsPermanent(err) {
+			break
+		}
+		timer := time.NewTimer(policy.Backoff(attempts))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if t.Metrics != nil {
+			t.Metrics.Retried(command)
+		}
+	}
+	if t.DeadLetters != nil {
+		t.DeadLetters.Add(command, err, attempts)
+	}
+	return err
+}
+
+// attempt runs a command once under its own timeout
+func attempt(ctx context.Context, command Command, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return command.Execute(ctx)
+}
+
+// retryingCommand adapts a queued command so the pool runs it with the queue's retry policy
+type retryingCommand struct {
+	queue   *TaskQueue
+	command Command
+	timeout time.Duration // Per-attempt timeout taken over from the pool
+}
+
+// Execute runs the wrapped command through the queue's retry logic
+func (r retryingCommand) Execute(ctx context.Context) error {
+	return r.queue.execute(ctx, r.command, r.timeout)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyCommand blocks until its context ends for the first failures attempts
+type flakyCommand struct {
+	failures int32
+	calls    *atomic.Int32
+}
+
+func (f flakyCommand) Execute(ctx context.Context) error {
+	if f.calls.Add(1) <= f.failures {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestBackoffNeverExceedsMaxDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond, Jitter: 1}
+	for retry := 1; retry <= 10; retry++ {
+		for i := 0; i < 100; i++ {
+			if delay := policy.Backoff(retry); delay < 0 || delay > policy.MaxDelay {
+				t.Fatalf("Backoff(%d) = %v, want within [0, %v]", retry, delay, policy.MaxDelay)
+			}
+		}
+	}
+}
+
+func TestPoolTimeoutAppliesPerAttempt(t *testing.T) {
+	calls := &atomic.Int32{}
+	queue := &TaskQueue{DeadLetters: NewDeadLetterQueue()}
+	queue.SetRetryPolicy(flakyCommand{}, RetryPolicy{MaxAttempts: 3, BaseDelay: 30 * time.Millisecond})
+	queue.AddCommand(flakyCommand{failures: 2, calls: calls})
+
+	report := queue.Run(context.Background(), NewPool(1, 20*time.Millisecond))
+	if failed := report.Failed(); len(failed) != 0 {
+		t.Fatalf("failed = %v, want the third attempt to succeed", failed[0].Err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("attempts = %d, want 3", got)
+	}
+	if queue.DeadLetters.Len() != 0 {
+		t.Fatalf("dead letters = %d, want 0", queue.DeadLetters.Len())
+	}
+}
+
+// permanentCommand fails with an error retrying cannot fix
+type permanentCommand struct {
+	calls *atomic.Int32
+}
+
+func (p permanentCommand) Execute(ctx context.Context) error {
+	p.calls.Add(1)
+	return Permanent(errors.New("invalid"))
+}
+
+func TestPermanentErrorsAreNotRetried(t *testing.T) {
+	calls := &atomic.Int32{}
+	queue := &TaskQueue{DeadLetters: NewDeadLetterQueue()}
+	queue.SetRetryPolicy(permanentCommand{}, RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond})
+	queue.AddCommand(permanentCommand{calls: calls})
+
+	err := queue.Command(context.Background())
+	if !IsPermanent(err) {
+		t.Fatalf("err = %v, want a permanent error", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("attempts = %d, want 1", got)
+	}
+	letters := queue.DeadLetters.List()
+	if len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Fatalf("dead letters = %+v, want one letter after one attempt", letters)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SMSSender delivers text messages
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, content string) error
+}
+
+// DefaultSMSSender is used by SendTel commands; when nil, messages are only printed
+var DefaultSMSSender SMSSender
+
+// smsRequest is the JSON body posted to the SMS gateway
+type smsRequest struct {
+	To      string `json:"to"`
+	Content string `json:"content"`
+}
+
+// HTTPSMSGateway delivers text messages by posting JSON to an HTTP gateway
+type HTTPSMSGateway struct {
+	Endpoint string       // URL that accepts POSTed messages
+	APIKey   string       // Optional bearer token
+	Client   *http.Client // HTTP client, http.DefaultClient when nil
+}
+
+// SendSMS posts the message to the gateway and fails on any non-2xx response
+func (g *HTTPSMSGateway) SendSMS(ctx context.Context, to, content string) error {
+	body, err := json.Marshal(smsRequest{To: to, Content: content})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("sms: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.APIKey)
+	}
+
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sms: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sms: gateway returned %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPSMSGatewayPostsMessage(t *testing.T) {
+	gateway := NewFakeSMSGateway()
+	defer gateway.Close()
+	sender := &HTTPSMSGateway{Endpoint: gateway.URL(), APIKey: "token"}
+
+	if err := sender.SendSMS(context.Background(), "11122223333", "账单已发送"); err != nil {
+		t.Fatal(err)
+	}
+	messages := gateway.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("messages = %d, want 1", len(messages))
+	}
+	want := ReceivedSMS{To: "11122223333", Content: "账单已发送", Authorization: "Bearer token"}
+	if messages[0] != want {
+		t.Fatalf("message = %+v, want %+v", messages[0], want)
+	}
+}
+
+func TestHTTPSMSGatewayReportsGatewayErrors(t *testing.T) {
+	gateway := NewFakeSMSGateway()
+	defer gateway.Close()
+	gateway.FailNext(1)
+	sender := &HTTPSMSGateway{Endpoint: gateway.URL()}
+
+	err := sender.SendSMS(context.Background(), "11122223333", "hi")
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("err = %v, want a 503 error", err)
+	}
+	if len(gateway.Messages()) != 0 {
+		t.Fatal("failed request was recorded")
+	}
+}
+
+func TestSendTelRetriesThroughGateway(t *testing.T) {
+	gateway := NewFakeSMSGateway()
+	defer gateway.Close()
+	DefaultSMSSender = &HTTPSMSGateway{Endpoint: gateway.URL()}
+	defer func() { DefaultSMSSender = nil }()
+
+	queue := NewTaskQueue()
+	queue.Metrics = NewMetrics()
+	queue.SetRetryPolicy(SendTel{}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	gateway.FailNext(2)
+	queue.AddCommand(&SendTel{To: "11122223333", Content: "告警"})
+	if err := queue.Command(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if messages := gateway.Messages(); len(messages) != 1 || messages[0].Content != "告警" {
+		t.Fatalf("messages = %+v, want the alert delivered once", messages)
+	}
+	var metrics strings.Builder
+	queue.Metrics.WritePrometheus(&metrics)
+	if !strings.Contains(metrics.String(), `task_queue_retried_total{type="SendTel"} 2`) {
+		t.Fatalf("metrics do not count two retries:\n%s", metrics.String())
+	}
+}
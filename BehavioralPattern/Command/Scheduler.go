@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timer is a stoppable one-shot timer
+type Timer interface {
+	C() <-chan time.Time // Channel that receives the time when the timer fires
+	Stop() bool          // Prevent the timer from firing
+}
+
+// Clock abstracts time so the scheduler can be driven by a fake clock
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTimerAt(deadline time.Time) Timer // Timer that fires once the clock reaches deadline
+}
+
+// RealClock is a Clock backed by the time package
+type RealClock struct{}
+
+// Now returns the current time
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a timer that fires after d
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// NewTimerAt creates a timer that fires at deadline
+func (RealClock) NewTimerAt(deadline time.Time) Timer {
+	return realTimer{time.NewTimer(time.Until(deadline))}
+}
+
+// realTimer adapts *time.Timer to the Timer interface
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time { return r.timer.C }
+func (r realTimer) Stop() bool          { return r.timer.Stop() }
+
+// FakeClock is a manually advanced Clock
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a fake clock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the fake current time
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer creates a timer that fires once the clock is advanced past d
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.newTimer(f.now.Add(d))
+}
+
+// NewTimerAt creates a timer that fires once the clock reaches deadline
+// A deadline that already passed fires immediately, so a timer armed after
+// Advance is never late.
+func (f *FakeClock) NewTimerAt(deadline time.Time) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.newTimer(deadline)
+}
+
+// newTimer registers a timer, the caller must hold f.mu
+func (f *FakeClock) newTimer(deadline time.Time) *fakeTimer {
+	timer := &fakeTimer{clock: f, deadline: deadline, c: make(chan time.Time, 1)}
+	if !deadline.After(f.now) {
+		timer.c <- f.now
+		return timer
+	}
+	f.timers = append(f.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward and fires every timer that became due
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	remaining := f.timers[:0]
+	for _, timer := range f.timers {
+		if timer.deadline.After(f.now) {
+			remaining = append(remaining, timer)
+			continue
+		}
+		timer.c <- f.now
+	}
+	f.timers = remaining
+}
+
+// fakeTimer is a Timer created by FakeClock
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Trigger decides when a scheduled command runs next
+type Trigger interface {
+	Next(after time.Time) time.Time // Next run strictly after the given time, zero when finished
+}
+
+// atTrigger runs a command once at a fixed time
+type atTrigger struct {
+	at time.Time
+}
+
+func (a atTrigger) Next(after time.Time) time.Time {
+	if a.at.After(after) {
+		return a.at
+	}
+	return time.Time{}
+}
+
+// intervalTrigger runs a command every interval starting from an anchor time
+type intervalTrigger struct {
+	anchor   time.Time
+	interval time.Duration
+}
+
+func (i intervalTrigger) Next(after time.Time) time.Time {
+	if after.Before(i.anchor) {
+		return i.anchor
+	}
+	// Stay aligned with the anchor so runs don't drift
+	periods := after.Sub(i.anchor)/i.interval + 1
+	return i.anchor.Add(periods * i.interval)
+}
+
+// scheduledJob is a command waiting in the scheduler
+type scheduledJob struct {
+	id      uint64
+	command Command
+	trigger Trigger
+	next    time.Time
+}
+
+// Scheduler runs commands at a fixed time, on an interval or on a cron schedule
+// Due commands are executed through the TaskQueue so its retry policies and
+// dead-letter queue apply. Runs missed while the scheduler was busy or stopped
+// are coalesced into a single run.
+type Scheduler struct {
+	mu      sync.Mutex
+	clock   Clock
+	queue   *TaskQueue
+	jobs    map[uint64]*scheduledJob
+	nextID  uint64
+	wake    chan struct{}                    // Signals the run loop that the jobs changed
+	OnError func(command Command, err error) // Optional callback for failed runs
+	OnRun   func(command Command, err error) // Optional callback after every run
+}
+
+// NewScheduler creates a scheduler that executes due commands through queue
+func NewScheduler(clock Clock, queue *TaskQueue) *Scheduler {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	if queue == nil {
+		queue = NewTaskQueue()
+	}
+	return &Scheduler{
+		clock:  clock,
+		queue:  queue,
+		jobs:   map[uint64]*scheduledJob{},
+		nextID: 1,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Schedule adds a command with a custom trigger and returns its job id
+func (s *Scheduler) Schedule(command Command, trigger Trigger) uint64 {
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	job := &scheduledJob{id: id, command: command, trigger: trigger}
+	job.next = trigger.Next(s.clock.Now().Add(-time.Nanosecond))
+	if !job.next.IsZero() {
+		s.jobs[id] = job
+	}
+	s.mu.Unlock()
+	s.notify()
+	return id
+}
+
+// ScheduleAt runs a command once at the given time
+func (s *Scheduler) ScheduleAt(command Command, at time.Time) uint64 {
+	return s.Schedule(command, atTrigger{at: at})
+}
+
+// ScheduleAfter runs a command once after the given delay
+func (s *Scheduler) ScheduleAfter(command Command, delay time.Duration) uint64 {
+	return s.ScheduleAt(command, s.clock.Now().Add(delay))
+}
+
+// ScheduleEvery runs a command repeatedly with a fixed interval, starting one interval from now
+func (s *Scheduler) ScheduleEvery(command Command, interval time.Duration) (uint64, error) {
+	if interval <= 0 {
+		return 0, fmt.Errorf("scheduler: interval %v must be positive", interval)
+	}
+	return s.Schedule(command, intervalTrigger{anchor: s.clock.Now().Add(interval), interval: interval}), nil
+}
+
+// ScheduleCron runs a command on a standard 5-field cron expression
+func (s *Scheduler) ScheduleCron(command Command, expr string) (uint64, error) {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return 0, err
+	}
+	return s.Schedule(command, schedule), nil
+}
+
+// Cancel removes a scheduled job and reports whether it existed
+func (s *Scheduler) Cancel(id uint64) bool {
+	s.mu.Lock()
+	_, ok := s.jobs[id]
+	delete(s.jobs, id)
+	s.mu.Unlock()
+	s.notify()
+	return ok
+}
+
+// NextRun returns the next run time of a job
+func (s *Scheduler) NextRun(id uint64) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return time.Time{}, false
+	}
+	return job.next, true
+}
+
+// notify wakes up the run loop without blocking
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// due removes the jobs that should run now, reschedules repeating ones
+// and returns the time of the next job (zero when none is left)
+func (s *Scheduler) due() ([]*scheduledJob, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+
+	var ready []*scheduledJob
+	for id, job := range s.jobs {
+		if job.next.After(now) {
+			continue
+		}
+		ready = append(ready, &scheduledJob{id: id, command: job.command, next: job.next})
+		job.next = job.trigger.Next(now)
+		if job.next.IsZero() {
+			delete(s.jobs, id)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		if ready[i].next.Equal(ready[j].next) {
+			return ready[i].id < ready[j].id
+		}
+		return ready[i].next.Before(ready[j].next)
+	})
+
+	var next time.Time
+	for _, job := range s.jobs {
+		if next.IsZero() || job.next.Before(next) {
+			next = job.next
+		}
+	}
+	return ready, next
+}
+
+// Run executes due commands until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		ready, next := s.due()
+		for _, job := range ready {
+			err := s.queue.execute(ctx, job.command, 0)
+			if err != nil && s.OnError != nil {
+				s.OnError(job.command, err)
+			}
+			if s.OnRun != nil {
+				s.OnRun(job.command, err)
+			}
+		}
+		if len(ready) > 0 {
+			continue
+		}
+
+		// Arm the timer at an absolute deadline so time passing between due
+		// and here, such as a FakeClock.Advance, cannot delay the run
+		var timer Timer
+		var fire <-chan time.Time
+		if !next.IsZero() {
+			timer = s.clock.NewTimerAt(next)
+			fire = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-s.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-fire:
+		}
+	}
+}
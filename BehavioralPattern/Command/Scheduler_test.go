@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// noopCommand is a command that does nothing, identified by its name
+type noopCommand struct {
+	name string
+}
+
+func (noopCommand) Execute(ctx context.Context) error {
+	return nil
+}
+
+// run records a scheduled command and the fake time it ran at
+type run struct {
+	name string
+	at   time.Time
+}
+
+// startScheduler runs s in the background and returns the channel of its runs
+func startScheduler(t *testing.T, clock *FakeClock, s *Scheduler) <-chan run {
+	t.Helper()
+	runs := make(chan run, 16)
+	s.OnRun = func(command Command, err error) {
+		runs <- run{name: command.(noopCommand).name, at: clock.Now()}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return runs
+}
+
+// expectRun waits for the next run and checks its command and time
+func expectRun(t *testing.T, runs <-chan run, name string, at time.Time) {
+	t.Helper()
+	select {
+	case r := <-runs:
+		if r.name != name || !r.at.Equal(at) {
+			t.Fatalf("run = %s at %v, want %s at %v", r.name, r.at, name, at)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%s did not run at %v", name, at)
+	}
+}
+
+// expectNoRun checks that nothing ran shortly after the clock moved
+func expectNoRun(t *testing.T, runs <-chan run) {
+	t.Helper()
+	select {
+	case r := <-runs:
+		t.Fatalf("unexpected run of %s at %v", r.name, r.at)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+var schedulerStart = time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+func TestSchedulerAt(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	s := NewScheduler(clock, NewTaskQueue())
+	id := s.ScheduleAt(noopCommand{"at"}, schedulerStart.Add(time.Hour))
+	runs := startScheduler(t, clock, s)
+
+	clock.Advance(30 * time.Minute)
+	expectNoRun(t, runs)
+	clock.Advance(30 * time.Minute)
+	expectRun(t, runs, "at", schedulerStart.Add(time.Hour))
+	if _, ok := s.NextRun(id); ok {
+		t.Fatal("one-shot job is still scheduled after running")
+	}
+}
+
+func TestSchedulerAdvanceBeforeTimerIsArmed(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	s := NewScheduler(clock, NewTaskQueue())
+	s.ScheduleAfter(noopCommand{"after"}, time.Hour)
+	// Advance races with the run loop computing and arming its timer
+	runs := startScheduler(t, clock, s)
+	clock.Advance(time.Hour)
+	expectRun(t, runs, "after", schedulerStart.Add(time.Hour))
+}
+
+func TestSchedulerEvery(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	s := NewScheduler(clock, NewTaskQueue())
+	id, err := s.ScheduleEvery(noopCommand{"every"}, 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := startScheduler(t, clock, s)
+
+	for i := 1; i <= 3; i++ {
+		clock.Advance(10 * time.Minute)
+		at := schedulerStart.Add(time.Duration(i) * 10 * time.Minute)
+		expectRun(t, runs, "every", at)
+		if next, _ := s.NextRun(id); !next.Equal(at.Add(10 * time.Minute)) {
+			t.Fatalf("NextRun = %v, want %v", next, at.Add(10*time.Minute))
+		}
+	}
+}
+
+func TestSchedulerCoalescesMissedRuns(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	s := NewScheduler(clock, NewTaskQueue())
+	s.ScheduleEvery(noopCommand{"every"}, 10*time.Minute)
+	runs := startScheduler(t, clock, s)
+
+	clock.Advance(35 * time.Minute)
+	expectRun(t, runs, "every", schedulerStart.Add(35*time.Minute))
+	expectNoRun(t, runs)
+}
+
+func TestSchedulerRejectsNonPositiveInterval(t *testing.T) {
+	s := NewScheduler(NewFakeClock(schedulerStart), NewTaskQueue())
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		if _, err := s.ScheduleEvery(noopCommand{"every"}, interval); err == nil {
+			t.Fatalf("ScheduleEvery(%v) succeeded, want an error", interval)
+		}
+	}
+	if len(s.jobs) != 0 {
+		t.Fatalf("rejected intervals left %d jobs", len(s.jobs))
+	}
+}
+
+func TestSchedulerCron(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	s := NewScheduler(clock, NewTaskQueue())
+	id, err := s.ScheduleCron(noopCommand{"cron"}, "30 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := startScheduler(t, clock, s)
+
+	clock.Advance(90 * time.Minute)
+	expectRun(t, runs, "cron", schedulerStart.Add(90*time.Minute))
+	want := time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+	if next, _ := s.NextRun(id); !next.Equal(want) {
+		t.Fatalf("NextRun = %v, want %v", next, want)
+	}
+	clock.Advance(24 * time.Hour)
+	expectRun(t, runs, "cron", want)
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	clock := NewFakeClock(schedulerStart)
+	s := NewScheduler(clock, NewTaskQueue())
+	id := s.ScheduleAfter(noopCommand{"cancelled"}, time.Hour)
+	runs := startScheduler(t, clock, s)
+
+	if !s.Cancel(id) {
+		t.Fatal("Cancel reported the job as missing")
+	}
+	clock.Advance(2 * time.Hour)
+	expectNoRun(t, runs)
+}
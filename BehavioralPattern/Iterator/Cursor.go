@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// PeekableIterator can look at the next element without consuming it
+type PeekableIterator[T any] interface {
+	Iterator[T]
+	Peek() (T, bool)
+}
+
+// BidirectionalIterator can move backwards as well as forwards
+type BidirectionalIterator[T any] interface {
+	Iterator[T]
+	Prev() (T, bool) // Returns the previous element and moves back over it
+}
+
+// SeekableIterator can jump to any position
+type SeekableIterator[T any] interface {
+	BidirectionalIterator[T]
+	Peek() (T, bool)
+	Reset()                  // Moves back to the first element
+	Seek(position int) error // Moves so that Next returns the element at position
+	Position() int           // Index of the element Next would return
+}
+
+// ListIterator is a SeekableIterator over a slice
+type ListIterator[T any] struct {
+	items    []T
+	position int // Index of the element Next returns
+}
+
+// NewListIterator creates a seekable iterator positioned at the first element
+func NewListIterator[T any](items []T) *ListIterator[T] {
+	return &ListIterator[T]{items: items}
+}
+
+// Next returns the element at the current position and moves forwards
+func (l *ListIterator[T]) Next() (T, bool) {
+	item, ok := l.Peek()
+	if ok {
+		l.position++
+	}
+	return item, ok
+}
+
+// Peek returns the element at the current position without moving
+func (l *ListIterator[T]) Peek() (T, bool) {
+	if l.position >= len(l.items) {
+		var zero T
+		return zero, false
+	}
+	return l.items[l.position], true
+}
+
+// Prev moves backwards and returns the element before the current position
+func (l *ListIterator[T]) Prev() (T, bool) {
+	if l.position == 0 {
+		var zero T
+		return zero, false
+	}
+	l.position--
+	return l.items[l.position], true
+}
+
+// Reset moves back to the first element
+func (l *ListIterator[T]) Reset() {
+	l.position = 0
+}
+
+// Seek moves to position, which may equal the length to mean the end
+func (l *ListIterator[T]) Seek(position int) error {
+	if position < 0 || position > len(l.items) {
+		return fmt.Errorf("seek %d: out of range [0, %d]", position, len(l.items))
+	}
+	l.position = position
+	return nil
+}
+
+// Position returns the index of the element Next would return
+func (l *ListIterator[T]) Position() int {
+	return l.position
+}
+
+// Cursor returns an opaque token that resumes iteration at the current position
+func (l *ListIterator[T]) Cursor() string {
+	return EncodeCursor(Cursor{Position: l.position})
+}
+
+// ResumeListIterator creates an iterator positioned at the given cursor
+func ResumeListIterator[T any](items []T, cursor string) (*ListIterator[T], error) {
+	it := NewListIterator(items)
+	if cursor == "" {
+		return it, nil
+	}
+	c, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if err := it.Seek(c.Position); err != nil {
+		return nil, fmt.Errorf("resume cursor: %w", err)
+	}
+	return it, nil
+}
+
+// Cursor is the position carried by a continuation token
+type Cursor struct {
+	Position int `json:"p"`
+}
+
+// ErrInvalidCursor is returned when a continuation token cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor serializes a cursor into an opaque URL-safe string
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a string produced by EncodeCursor
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Position < 0 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// PeekIterator adds Peek to any Iterator by buffering one element
+type PeekIterator[T any] struct {
+	it     Iterator[T]
+	next   T
+	ok     bool
+	peeked bool
+}
+
+// Peekable wraps an iterator so its next element can be inspected
+func Peekable[T any](it Iterator[T]) *PeekIterator[T] {
+	return &PeekIterator[T]{it: it}
+}
+
+// Peek returns the next element without consuming it
+func (p *PeekIterator[T]) Peek() (T, bool) {
+	if !p.peeked {
+		p.next, p.ok = p.it.Next()
+		p.peeked = true
+	}
+	return p.next, p.ok
+}
+
+// Next returns the next element
+func (p *PeekIterator[T]) Next() (T, bool) {
+	item, ok := p.Peek()
+	p.peeked = false
+	return item, ok
+}
+
+// ListBooks returns up to limit books starting at cursor, plus the cursor of the next
+// page, which is empty when there are no more books
+// The limit must be positive, otherwise a client following cursors would never advance.
+func ListBooks(books []*Book, cursor string, limit int) ([]*Book, string, error) {
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("list books: limit %d must be positive", limit)
+	}
+	it, err := ResumeListIterator(books, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	page := Collect(Take[*Book](it, limit))
+	if _, ok := it.Peek(); !ok {
+		return page, "", nil
+	}
+	return page, it.Cursor(), nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListBooksFollowsCursors(t *testing.T) {
+	books := catalogBooks(5)
+	var got []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(books) {
+			t.Fatal("cursors do not terminate")
+		}
+		page, next, err := ListBooks(books, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, book := range page {
+			got = append(got, book.Title)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if strings.Join(got, ",") != "0,1,2,3,4" {
+		t.Fatalf("titles = %v, want 0..4", got)
+	}
+}
+
+func TestListBooksRejectsNonPositiveLimit(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		if _, _, err := ListBooks(catalogBooks(3), "", limit); err == nil {
+			t.Errorf("ListBooks with limit %d succeeded, want an error", limit)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+// NextFunc adapts a plain function to the Iterator interface
+type NextFunc[T any] func() (T, bool)
+
+// Next calls the function
+func (f NextFunc[T]) Next() (T, bool) {
+	return f()
+}
+
+// Pair holds two values produced together, such as by Zip or FromMap
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// FromSlice iterates over the elements of a slice
+func FromSlice[T any](items []T) Iterator[T] {
+	position := 0
+	return NextFunc[T](func() (T, bool) {
+		if position >= len(items) {
+			var zero T
+			return zero, false
+		}
+		item := items[position]
+		position++
+		return item, true
+	})
+}
+
+// FromMap iterates over the key/value pairs of a map in unspecified order
+// The keys are captured when the iterator is created; values are read lazily
+func FromMap[K comparable, V any](m map[K]V) Iterator[Pair[K, V]] {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return Map(FromSlice(keys), func(key K) Pair[K, V] {
+		return Pair[K, V]{First: key, Second: m[key]}
+	})
+}
+
+// FromChannel iterates over the values received from a channel until it is closed
+func FromChannel[T any](ch <-chan T) Iterator[T] {
+	return NextFunc[T](func() (T, bool) {
+		item, ok := <-ch
+		return item, ok
+	})
+}
+
+// Map lazily transforms every element
+func Map[T, U any](it Iterator[T], f func(T) U) Iterator[U] {
+	return NextFunc[U](func() (U, bool) {
+		item, ok := it.Next()
+		if !ok {
+			var zero U
+			return zero, false
+		}
+		return f(item), true
+	})
+}
+
+// Filter lazily keeps the elements for which keep returns true
+func Filter[T any](it Iterator[T], keep func(T) bool) Iterator[T] {
+	return NextFunc[T](func() (T, bool) {
+		for {
+			item, ok := it.Next()
+			if !ok || keep(item) {
+				return item, ok
+			}
+		}
+	})
+}
+
+// Take yields at most n elements
+func Take[T any](it Iterator[T], n int) Iterator[T] {
+	return NextFunc[T](func() (T, bool) {
+		if n <= 0 {
+			var zero T
+			return zero, false
+		}
+		n--
+		return it.Next()
+	})
+}
+
+// Skip drops the first n elements
+func Skip[T any](it Iterator[T], n int) Iterator[T] {
+	return NextFunc[T](func() (T, bool) {
+		for ; n > 0; n-- {
+			if _, ok := it.Next(); !ok {
+				n = 0
+				var zero T
+				return zero, false
+			}
+		}
+		return it.Next()
+	})
+}
+
+// Zip pairs up elements of two iterators and stops when either is exhausted
+func Zip[A, B any](a Iterator[A], b Iterator[B]) Iterator[Pair[A, B]] {
+	return NextFunc[Pair[A, B]](func() (Pair[A, B], bool) {
+		first, ok := a.Next()
+		if !ok {
+			return Pair[A, B]{}, false
+		}
+		second, ok := b.Next()
+		if !ok {
+			return Pair[A, B]{}, false
+		}
+		return Pair[A, B]{First: first, Second: second}, true
+	})
+}
+
+// Chain yields the elements of each iterator in turn
+func Chain[T any](its ...Iterator[T]) Iterator[T] {
+	return NextFunc[T](func() (T, bool) {
+		for len(its) > 0 {
+			if item, ok := its[0].Next(); ok {
+				return item, true
+			}
+			its = its[1:]
+		}
+		var zero T
+		return zero, false
+	})
+}
+
+// Chunk groups elements into slices of size n; the last chunk may be shorter
+func Chunk[T any](it Iterator[T], n int) Iterator[[]T] {
+	if n < 1 {
+		n = 1
+	}
+	return NextFunc[[]T](func() ([]T, bool) {
+		chunk := make([]T, 0, n)
+		for len(chunk) < n {
+			item, ok := it.Next()
+			if !ok {
+				break
+			}
+			chunk = append(chunk, item)
+		}
+		return chunk, len(chunk) > 0
+	})
+}
+
+// FlatMap maps every element to an iterator and yields their elements in turn
+func FlatMap[T, U any](it Iterator[T], f func(T) Iterator[U]) Iterator[U] {
+	var current Iterator[U]
+	return NextFunc[U](func() (U, bool) {
+		for {
+			if current != nil {
+				if item, ok := current.Next(); ok {
+					return item, true
+				}
+			}
+			next, ok := it.Next()
+			if !ok {
+				var zero U
+				return zero, false
+			}
+			current = f(next)
+		}
+	})
+}
+
+// Collect consumes the iterator and returns its elements as a slice
+func Collect[T any](it Iterator[T]) []T {
+	var items []T
+	for item, ok := it.Next(); ok; item, ok = it.Next() {
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func expectItems[T comparable](t *testing.T, got, want []T) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestFromSliceAndCollect(t *testing.T) {
+	expectItems(t, Collect(FromSlice([]int{1, 2, 3})), []int{1, 2, 3})
+	if got := Collect(FromSlice([]int{})); len(got) != 0 {
+		t.Fatalf("empty slice yielded %v", got)
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	pairs := Collect(FromMap(map[string]int{"a": 1, "b": 2}))
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].First < pairs[j].First })
+	expectItems(t, pairs, []Pair[string, int]{{"a", 1}, {"b", 2}})
+}
+
+func TestFromChannel(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	it := FromChannel(ch)
+	expectItems(t, Collect(it), []int{1, 2})
+	if _, ok := it.Next(); ok {
+		t.Fatal("Next after the channel closed returned a value")
+	}
+}
+
+func TestMapAndFilter(t *testing.T) {
+	even := Filter(FromSlice([]int{1, 2, 3, 4, 5, 6}), func(n int) bool { return n%2 == 0 })
+	expectItems(t, Collect(Map(even, strconv.Itoa)), []string{"2", "4", "6"})
+	none := Filter(FromSlice([]int{1, 3}), func(n int) bool { return n%2 == 0 })
+	if got := Collect(none); len(got) != 0 {
+		t.Fatalf("Filter kept %v", got)
+	}
+}
+
+func TestTakeAndSkip(t *testing.T) {
+	items := []int{1, 2, 3, 4}
+	for _, tc := range []struct {
+		name string
+		it   Iterator[int]
+		want []int
+	}{
+		{"take 2", Take(FromSlice(items), 2), []int{1, 2}},
+		{"take 0", Take(FromSlice(items), 0), nil},
+		{"take -1", Take(FromSlice(items), -1), nil},
+		{"take more than available", Take(FromSlice(items), 10), items},
+		{"skip 2", Skip(FromSlice(items), 2), []int{3, 4}},
+		{"skip 0", Skip(FromSlice(items), 0), items},
+		{"skip -1", Skip(FromSlice(items), -1), items},
+		{"skip everything", Skip(FromSlice(items), 10), nil},
+		{"skip then take", Take(Skip(FromSlice(items), 1), 2), []int{2, 3}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			expectItems(t, Collect(tc.it), tc.want)
+		})
+	}
+}
+
+func TestTakeStopsPullingSource(t *testing.T) {
+	pulled := 0
+	source := NextFunc[int](func() (int, bool) {
+		pulled++
+		return pulled, true
+	})
+	expectItems(t, Collect(Take[int](source, 3)), []int{1, 2, 3})
+	if pulled != 3 {
+		t.Fatalf("Take pulled %d elements from an infinite source, want 3", pulled)
+	}
+}
+
+func TestZipUnequalLengths(t *testing.T) {
+	short := Collect(Zip(FromSlice([]int{1, 2, 3}), FromSlice([]string{"a", "b"})))
+	expectItems(t, short, []Pair[int, string]{{1, "a"}, {2, "b"}})
+	short = Collect(Zip(FromSlice([]int{1}), FromSlice([]string{"a", "b"})))
+	expectItems(t, short, []Pair[int, string]{{1, "a"}})
+	if got := Collect(Zip(FromSlice([]int{}), FromSlice([]string{"a"}))); len(got) != 0 {
+		t.Fatalf("zip with an empty iterator yielded %v", got)
+	}
+}
+
+func TestChain(t *testing.T) {
+	chained := Chain(FromSlice([]int{1}), FromSlice([]int{}), FromSlice([]int{2, 3}))
+	expectItems(t, Collect(chained), []int{1, 2, 3})
+	if got := Collect(Chain[int]()); len(got) != 0 {
+		t.Fatalf("empty chain yielded %v", got)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	chunks := Collect(Chunk(FromSlice([]int{1, 2, 3, 4, 5}), 2))
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, want 3", len(chunks))
+	}
+	expectItems(t, chunks[0], []int{1, 2})
+	expectItems(t, chunks[2], []int{5})
+
+	// Sizes below 1 fall back to single elements
+	for _, n := range []int{0, -3} {
+		if got := Collect(Chunk(FromSlice([]int{1, 2}), n)); len(got) != 2 || len(got[0]) != 1 {
+			t.Fatalf("Chunk(%d) = %v", n, got)
+		}
+	}
+	if got := Collect(Chunk(FromSlice([]int{}), 2)); len(got) != 0 {
+		t.Fatalf("chunking an empty iterator yielded %v", got)
+	}
+}
+
+func TestFlatMap(t *testing.T) {
+	repeat := func(n int) Iterator[int] {
+		items := make([]int, n)
+		for i := range items {
+			items[i] = n
+		}
+		return FromSlice(items)
+	}
+	// Empty inner iterators, including leading and trailing ones, are skipped
+	expectItems(t, Collect(FlatMap(FromSlice([]int{0, 2, 0, 0, 1, 0}), repeat)), []int{2, 2, 1})
+	if got := Collect(FlatMap(FromSlice([]int{0, 0}), repeat)); len(got) != 0 {
+		t.Fatalf("only empty inner iterators yielded %v", got)
+	}
+}
@@ -20,11 +20,18 @@ Benefits:
 
 package main
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Book represents an element in the collection
 type Book struct {
-	Title string // Book title
+	Title string `csv:"title" json:"title"` // Book title
 }
 
 // BookIterator implements the iterator for a collection of books
@@ -41,10 +48,9 @@ func NewBookIterator(book []*Book) *BookIterator {
 	}
 }
 
-// Iterator defines the interface for traversing the collection
-type Iterator interface {
-	HasNext() bool // Checks if there are more elements
-	Next() *Book   // Returns the next element
+// Iterator defines the interface for traversing a collection of any element type
+type Iterator[T any] interface {
+	Next() (T, bool) // Returns the next element, or false when the iteration is done
 }
 
 // HasNext checks if there are more books to iterate over
@@ -56,19 +62,18 @@ func (b *BookIterator) HasNext() bool {
 }
 
 // Next returns the next book in the collection
-func (b *BookIterator) Next() *Book {
+func (b *BookIterator) Next() (*Book, bool) {
 	if !b.HasNext() {
-		return nil
+		return nil, false
 	}
 	book := b.Books[b.position]
 	b.position++
-	return book
+	return book, true
 }
 
 // IteratorFunc demonstrates using the iterator to process all books
-func IteratorFunc(iterator Iterator) {
-	for iterator.HasNext() {
-		book := iterator.Next()
+func IteratorFunc(iterator Iterator[*Book]) {
+	for book, ok := iterator.Next(); ok; book, ok = iterator.Next() {
 		fmt.Println(book.Title)
 	}
 }
@@ -86,4 +91,89 @@ func main() {
 	bookIterator := NewBookIterator(book)
 	// Use the iterator to process all books
 	IteratorFunc(bookIterator)
+
+	// Compose lazy combinators over the same books
+	title := func(b *Book) string { return b.Title }
+	fmt.Println(Collect(Filter(Map[*Book](NewBookIterator(book), title), func(t string) bool {
+		return t != "XX开发"
+	})))
+	fmt.Println(Collect(Chunk(Map(FromSlice(book), title), 2)))
+	numbered := Zip(Skip(FromSlice([]int{0, 1, 2, 3}), 1), Map(FromSlice(book), title))
+	for pair, ok := numbered.Next(); ok; pair, ok = numbered.Next() {
+		fmt.Println(pair.First, pair.Second)
+	}
+
+	// Range over the same iterator with a native loop
+	for b := range NewBookIterator(book).All() {
+		fmt.Println(b.Title)
+	}
+	// And feed a native sequence to an IteratorFunc-style consumer
+	pull := FromSeq(slices.Values(book))
+	defer pull.Stop()
+	IteratorFunc(pull)
+
+	// Page through a catalog two books at a time while the next page is prefetched
+	// FetchBooksOverHTTP builds the same kind of FetchPage for a remote catalog
+	pages := NewPageIterator(context.Background(), func(ctx context.Context, token string) ([]*Book, string, error) {
+		start, _ := strconv.Atoi(token)
+		end := min(start+2, len(book))
+		next := ""
+		if end < len(book) {
+			next = strconv.Itoa(end)
+		}
+		return book[start:end], next, nil
+	})
+	defer pages.Close()
+	IteratorFunc(pages)
+	if err := pages.Err(); err != nil {
+		fmt.Println(err)
+	}
+
+	// Modifying a shelf during iteration fails fast, while a snapshot is unaffected
+	shelf := NewBookShelf(book...)
+	snapshot := shelf.Snapshot()
+	failFast := shelf.Iterator()
+	failFast.Next()
+	shelf.Add(&Book{Title: "后端开发"}) // Backend Development
+	if _, ok := failFast.Next(); !ok {
+		fmt.Println(failFast.Err())
+	}
+	fmt.Println(len(Collect(snapshot)), shelf.Len())
+
+	// Hand out continuation tokens for a paginated listing
+	cursor := ""
+	for {
+		page, next, err := ListBooks(book, cursor, 2)
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		fmt.Println(len(page), next)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	// Stream books from CSV and JSON Lines without loading the whole input
+	csvBooks, err := NewCSVIterator[Book](strings.NewReader("title,price\nGo开发,59\n前端开发,49\n"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	IteratorFunc(csvBooks)
+	jsonBooks := NewJSONLinesIterator[Book](strings.NewReader("{\"title\":\"Go开发\"}\n\n{\"title\":\n"))
+	IteratorFunc(jsonBooks)
+	fmt.Println(jsonBooks.Err())
+
+	// Enrich books through slow lookups in parallel while keeping their order
+	enriched := ParallelMap(context.Background(), FromSlice(book), 3, func(ctx context.Context, b *Book) (*Book, error) {
+		time.Sleep(10 * time.Millisecond)           // Simulate a slow metadata lookup
+		return &Book{Title: b.Title + "（第二版）"}, nil // Second edition
+	})
+	defer enriched.Close()
+	IteratorFunc(enriched)
+	if err := enriched.Err(); err != nil {
+		fmt.Println(err)
+	}
 }
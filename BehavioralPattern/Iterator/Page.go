@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// FetchPage loads one page of items for a page token
+// The first page is requested with an empty token, and an empty next token marks the last page.
+type FetchPage[T any] func(ctx context.Context, token string) (items []T, nextToken string, err error)
+
+// pageResult is a page delivered by the prefetching goroutine
+type pageResult[T any] struct {
+	items []T
+	err   error
+}
+
+// PageIterator iterates over paginated remote data
+// The next page is fetched in a background goroutine while the current one is consumed.
+// Next reports false at the end of the data, on a fetch error or when the context is
+// cancelled; Err tells these cases apart.
+type PageIterator[T any] struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pages   chan pageResult[T]
+	current []T
+	err     error
+	done    bool // Set once the iteration has ended for any reason
+	once    sync.Once
+}
+
+// NewPageIterator starts fetching pages in the background
+func NewPageIterator[T any](ctx context.Context, fetch FetchPage[T]) *PageIterator[T] {
+	ctx, cancel := context.WithCancel(ctx)
+	p := &PageIterator[T]{
+		ctx:    ctx,
+		cancel: cancel,
+		pages:  make(chan pageResult[T], 1), // Hold one page ahead of the consumer
+	}
+	go p.prefetch(fetch)
+	return p
+}
+
+// prefetch fetches pages in order until the last page, an error or cancellation
+func (p *PageIterator[T]) prefetch(fetch FetchPage[T]) {
+	defer close(p.pages)
+	token := ""
+	for {
+		items, next, err := fetch(p.ctx, token)
+		if err == nil {
+			err = p.ctx.Err()
+		}
+		select {
+		case p.pages <- pageResult[T]{items: items, err: err}:
+		case <-p.ctx.Done():
+			return
+		}
+		if err != nil || next == "" {
+			return
+		}
+		token = next
+	}
+}
+
+// Next returns the next item, waiting for the next page when needed
+// Once the iterator is closed or its context cancelled, Next reports false even
+// if items of the current page are left.
+func (p *PageIterator[T]) Next() (T, bool) {
+	var zero T
+	if !p.done && p.ctx.Err() != nil {
+		p.finish(p.ctx.Err())
+	}
+	if p.done {
+		return zero, false
+	}
+	for len(p.current) == 0 {
+		if p.done {
+			return zero, false
+		}
+		select {
+		case page, ok := <-p.pages:
+			if !ok {
+				// Cancellation closes the channel without sending an error
+				p.finish(p.ctx.Err())
+				return zero, false
+			}
+			if page.err != nil {
+				p.finish(page.err)
+				return zero, false
+			}
+			p.current = page.items
+		case <-p.ctx.Done():
+			p.finish(p.ctx.Err())
+			return zero, false
+		}
+	}
+	item := p.current[0]
+	p.current = p.current[1:]
+	return item, true
+}
+
+// finish ends the iteration with err and stops the prefetching goroutine
+func (p *PageIterator[T]) finish(err error) {
+	p.done = true
+	p.err = err
+	p.current = nil
+	p.Close()
+}
+
+// Err returns the error that stopped the iteration, or nil at the normal end
+func (p *PageIterator[T]) Err() error {
+	return p.err
+}
+
+// Close stops prefetching; it is safe to call more than once
+func (p *PageIterator[T]) Close() {
+	p.once.Do(p.cancel)
+}
+
+// bookPage is the JSON body of a catalog page
+type bookPage struct {
+	Books         []*Book `json:"books"`
+	NextPageToken string  `json:"next_page_token"`
+}
+
+// FetchBooksOverHTTP pages through a catalog endpoint that accepts a page_token query
+// parameter and returns {"books": [...], "next_page_token": "..."}
+func FetchBooksOverHTTP(client *http.Client, endpoint string) FetchPage[*Book] {
+	return func(ctx context.Context, token string) ([]*Book, string, error) {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, "", err
+		}
+		if token != "" {
+			query := u.Query()
+			query.Set("page_token", token)
+			u.RawQuery = query.Encode()
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", fmt.Errorf("fetch page %q: %s", token, resp.Status)
+		}
+		var page bookPage
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			return nil, "", fmt.Errorf("fetch page %q: %w", token, err)
+		}
+		return page.Books, page.NextPageToken, nil
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// catalogHandler serves books in pages of pageSize, failing with 500 for the
+// page that starts at failAt when failAt is positive
+func catalogHandler(books []*Book, pageSize, failAt int, requests *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		start := 0
+		if token := r.URL.Query().Get("page_token"); token != "" {
+			n, err := strconv.Atoi(token)
+			if err != nil || n < 0 || n > len(books) {
+				http.Error(w, "invalid page token", http.StatusBadRequest)
+				return
+			}
+			start = n
+		}
+		if failAt > 0 && start == failAt {
+			http.Error(w, "catalog unavailable", http.StatusInternalServerError)
+			return
+		}
+		end := min(start+pageSize, len(books))
+		page := bookPage{Books: books[start:end]}
+		if end < len(books) {
+			page.NextPageToken = strconv.Itoa(end)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	})
+}
+
+// catalogBooks returns n books titled "0", "1", ...
+func catalogBooks(n int) []*Book {
+	books := make([]*Book, n)
+	for i := range books {
+		books[i] = &Book{Title: strconv.Itoa(i)}
+	}
+	return books
+}
+
+// titles drains the iterator and returns the book titles in order
+func titles(p *PageIterator[*Book]) []string {
+	var got []string
+	for book, ok := p.Next(); ok; book, ok = p.Next() {
+		got = append(got, book.Title)
+	}
+	return got
+}
+
+// waitStopped fails the test unless the prefetching goroutine exits
+func waitStopped(t *testing.T, p *PageIterator[*Book]) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-p.pages:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("prefetching goroutine is still running")
+		}
+	}
+}
+
+func TestPageIteratorOverHTTPKeepsOrder(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(catalogHandler(catalogBooks(5), 2, 0, &requests))
+	defer server.Close()
+
+	pages := NewPageIterator(context.Background(), FetchBooksOverHTTP(server.Client(), server.URL))
+	defer pages.Close()
+	if got := strings.Join(titles(pages), ","); got != "0,1,2,3,4" {
+		t.Fatalf("titles = %s, want 0,1,2,3,4", got)
+	}
+	if err := pages.Err(); err != nil {
+		t.Fatalf("Err = %v, want nil", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("requests = %d, want 3", got)
+	}
+	waitStopped(t, pages)
+}
+
+func TestPageIteratorSurfacesFetchError(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(catalogHandler(catalogBooks(5), 2, 2, &requests))
+	defer server.Close()
+
+	pages := NewPageIterator(context.Background(), FetchBooksOverHTTP(server.Client(), server.URL))
+	defer pages.Close()
+	if got := strings.Join(titles(pages), ","); got != "0,1" {
+		t.Fatalf("titles = %s, want the first page only", got)
+	}
+	if err := pages.Err(); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("Err = %v, want the 500 from the second page", err)
+	}
+	if _, ok := pages.Next(); ok {
+		t.Fatal("Next succeeded after the error")
+	}
+}
+
+func TestPageIteratorEmptyFirstPage(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(catalogHandler(nil, 2, 0, &requests))
+	defer server.Close()
+
+	pages := NewPageIterator(context.Background(), FetchBooksOverHTTP(server.Client(), server.URL))
+	defer pages.Close()
+	if got := titles(pages); len(got) != 0 {
+		t.Fatalf("titles = %v, want none", got)
+	}
+	if err := pages.Err(); err != nil {
+		t.Fatalf("Err = %v, want nil", err)
+	}
+	waitStopped(t, pages)
+}
+
+func TestPageIteratorSkipsEmptyMiddlePage(t *testing.T) {
+	pages := NewPageIterator(context.Background(), func(ctx context.Context, token string) ([]*Book, string, error) {
+		switch token {
+		case "":
+			return catalogBooks(1), "empty", nil
+		case "empty":
+			return nil, "last", nil
+		default:
+			return []*Book{{Title: "last"}}, "", nil
+		}
+	})
+	defer pages.Close()
+	if got := strings.Join(titles(pages), ","); got != "0,last" {
+		t.Fatalf("titles = %s, want 0,last", got)
+	}
+}
+
+func TestPageIteratorCloseMidIteration(t *testing.T) {
+	var fetches atomic.Int32
+	// An endless catalog whose fetches block until cancelled after the first two pages
+	pages := NewPageIterator(context.Background(), func(ctx context.Context, token string) ([]*Book, string, error) {
+		if fetches.Add(1) > 2 {
+			<-ctx.Done()
+			return nil, "", ctx.Err()
+		}
+		return catalogBooks(3), "more", nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, ok := pages.Next(); !ok {
+			t.Fatalf("Next %d failed: %v", i, pages.Err())
+		}
+	}
+	pages.Close()
+	if _, ok := pages.Next(); ok {
+		t.Fatal("Next succeeded after Close")
+	}
+	if err := pages.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Err = %v, want context.Canceled", err)
+	}
+	waitStopped(t, pages)
+}
+
+func TestPageIteratorParentContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := NewPageIterator(ctx, func(ctx context.Context, token string) ([]*Book, string, error) {
+		<-ctx.Done()
+		return nil, "", ctx.Err()
+	})
+	defer pages.Close()
+	cancel()
+	if _, ok := pages.Next(); ok {
+		t.Fatal("Next succeeded after cancellation")
+	}
+	if err := pages.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Err = %v, want context.Canceled", err)
+	}
+	waitStopped(t, pages)
+}
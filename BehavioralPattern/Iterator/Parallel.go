@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// parallelResult is the outcome of mapping one element
+type parallelResult[U any] struct {
+	value U
+	err   error
+}
+
+// parallelJob is one element waiting for a worker and the slot for its result
+type parallelJob[T, U any] struct {
+	item T
+	slot chan parallelResult[U]
+}
+
+// ParallelIterator yields the results of a ParallelMap in input order
+// Its Next must be called from a single goroutine, like any other Iterator.
+type ParallelIterator[U any] struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	order  chan chan parallelResult[U] // Result slots in input order
+	done   bool
+	err    error
+
+	failOnce sync.Once
+	failure  error // First error returned by the mapping function
+}
+
+// ParallelMap applies f to the elements of it using the given number of goroutines
+// Results come out in the same order as the input. At most 2*workers elements are
+// in flight at once, so a slow consumer does not cause unbounded buffering. The
+// first error, or cancellation of ctx, stops the pipeline and is reported by Err.
+// The input iterator is read from a single background goroutine.
+func ParallelMap[T, U any](ctx context.Context, it Iterator[T], workers int, f func(context.Context, T) (U, error)) *ParallelIterator[U] {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p := &ParallelIterator[U]{
+		ctx:    ctx,
+		cancel: cancel,
+		order:  make(chan chan parallelResult[U], 2*workers),
+	}
+	jobs := make(chan parallelJob[T, U])
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				value, err := f(ctx, job.item)
+				if err != nil {
+					p.fail(err)
+				}
+				job.slot <- parallelResult[U]{value: value, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(p.order)
+		defer close(jobs)
+		for ctx.Err() == nil {
+			item, ok := it.Next()
+			if !ok {
+				return
+			}
+			slot := make(chan parallelResult[U], 1)
+			select {
+			case p.order <- slot:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- parallelJob[T, U]{item: item, slot: slot}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// fail records the first error and stops the pipeline
+func (p *ParallelIterator[U]) fail(err error) {
+	p.failOnce.Do(func() {
+		p.failure = err
+		p.cancel()
+	})
+}
+
+// finish ends the iteration, preferring the mapping error over the cancellation it caused
+func (p *ParallelIterator[U]) finish(err error) {
+	p.done = true
+	p.fail(err)
+	p.err = p.failure
+	p.cancel()
+}
+
+// Next returns the next result in input order
+// Once the pipeline is stopped, results already buffered are discarded.
+func (p *ParallelIterator[U]) Next() (U, bool) {
+	var zero U
+	if !p.done && p.ctx.Err() != nil {
+		p.finish(p.ctx.Err())
+	}
+	if p.done {
+		return zero, false
+	}
+	select {
+	case slot, ok := <-p.order:
+		if !ok {
+			p.finish(p.ctx.Err())
+			return zero, false
+		}
+		select {
+		case result := <-slot:
+			if result.err != nil {
+				p.finish(result.err)
+				return zero, false
+			}
+			return result.value, true
+		case <-p.ctx.Done():
+			p.finish(p.ctx.Err())
+			return zero, false
+		}
+	case <-p.ctx.Done():
+		p.finish(p.ctx.Err())
+		return zero, false
+	}
+}
+
+// Err returns the error that stopped the pipeline, or nil at the normal end
+func (p *ParallelIterator[U]) Err() error {
+	return p.err
+}
+
+// Close stops the pipeline; it is safe to call more than once
+func (p *ParallelIterator[U]) Close() {
+	p.cancel()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingInput yields 0..n-1 and counts how many elements were pulled
+func countingInput(n int, pulled *atomic.Int32) Iterator[int] {
+	return NextFunc[int](func() (int, bool) {
+		next := int(pulled.Load())
+		if next >= n {
+			return 0, false
+		}
+		pulled.Add(1)
+		return next, true
+	})
+}
+
+// drainParallel collects results until the iterator stops
+func drainParallel(p *ParallelIterator[int]) []int {
+	var got []int
+	for value, ok := p.Next(); ok; value, ok = p.Next() {
+		got = append(got, value)
+	}
+	return got
+}
+
+// waitGoroutines fails the test unless the goroutine count drops back to want
+func waitGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running, want %d", runtime.NumGoroutine(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestParallelMapKeepsOrderUnderUnevenLatency(t *testing.T) {
+	var pulled atomic.Int32
+	p := ParallelMap(context.Background(), countingInput(20, &pulled), 4, func(ctx context.Context, n int) (int, error) {
+		time.Sleep(time.Duration((20-n)%4) * time.Millisecond)
+		return n * n, nil
+	})
+	defer p.Close()
+	got := drainParallel(p)
+	if p.Err() != nil {
+		t.Fatal(p.Err())
+	}
+	if len(got) != 20 {
+		t.Fatalf("got %d results, want 20", len(got))
+	}
+	for i, value := range got {
+		if value != i*i {
+			t.Fatalf("result %d = %d, want %d", i, value, i*i)
+		}
+	}
+}
+
+func TestParallelMapBoundsInFlight(t *testing.T) {
+	var pulled, running, peak atomic.Int32
+	const workers = 2
+	p := ParallelMap(context.Background(), countingInput(100, &pulled), workers, func(ctx context.Context, n int) (int, error) {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return n, nil
+	})
+	defer p.Close()
+
+	// Without a consumer the pipeline stops after filling its buffer
+	time.Sleep(50 * time.Millisecond)
+	if got := pulled.Load(); got > 2*workers+1 {
+		t.Fatalf("pulled %d elements without a consumer, want at most %d", got, 2*workers+1)
+	}
+	if got := len(drainParallel(p)); got != 100 {
+		t.Fatalf("got %d results, want 100", got)
+	}
+	if got := peak.Load(); got > workers {
+		t.Fatalf("%d calls ran at once, want at most %d", got, workers)
+	}
+}
+
+func TestParallelMapStopsAtFirstError(t *testing.T) {
+	var pulled atomic.Int32
+	failure := errors.New("boom")
+	p := ParallelMap(context.Background(), countingInput(1000, &pulled), 3, func(ctx context.Context, n int) (int, error) {
+		if n == 5 {
+			return 0, failure
+		}
+		return n, nil
+	})
+	defer p.Close()
+	got := drainParallel(p)
+	if !errors.Is(p.Err(), failure) {
+		t.Fatalf("Err = %v, want the mapping error", p.Err())
+	}
+	for i, value := range got {
+		if value != i || i >= 5 {
+			t.Fatalf("results %v are not an in-order prefix before the failure", got)
+		}
+	}
+	if n := pulled.Load(); n > 50 {
+		t.Fatalf("pulled %d elements after the error, want the pipeline stopped", n)
+	}
+	if _, ok := p.Next(); ok {
+		t.Fatal("Next succeeded after an error")
+	}
+}
+
+func TestParallelMapContextCancelled(t *testing.T) {
+	var pulled atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	p := ParallelMap(ctx, countingInput(1000, &pulled), 2, func(ctx context.Context, n int) (int, error) {
+		return n, nil
+	})
+	defer p.Close()
+	if _, ok := p.Next(); !ok {
+		t.Fatal("first Next failed")
+	}
+	cancel()
+	drainParallel(p)
+	if !errors.Is(p.Err(), context.Canceled) {
+		t.Fatalf("Err = %v, want context.Canceled", p.Err())
+	}
+}
+
+func TestParallelMapCloseStopsGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	var pulled atomic.Int32
+	p := ParallelMap(context.Background(), countingInput(1000, &pulled), 4, func(ctx context.Context, n int) (int, error) {
+		select {
+		case <-time.After(time.Millisecond):
+			return n, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	})
+	for i := 0; i < 3; i++ {
+		p.Next()
+	}
+	p.Close()
+	p.Close() // Safe to call twice
+	waitGoroutines(t, before)
+	if _, ok := p.Next(); ok {
+		t.Fatal("Next succeeded after Close")
+	}
+}
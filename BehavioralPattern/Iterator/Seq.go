@@ -0,0 +1,80 @@
+package main
+
+import "iter"
+
+// Seq exposes an Iterator as an iter.Seq so it can be used in a range loop
+// Breaking out of the loop leaves the remaining elements in the iterator
+func Seq[T any](it Iterator[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for item, ok := it.Next(); ok; item, ok = it.Next() {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Seq2 exposes an Iterator of pairs, such as one made by FromMap or Zip, as an iter.Seq2
+func Seq2[K, V any](it Iterator[Pair[K, V]]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for pair, ok := it.Next(); ok; pair, ok = it.Next() {
+			if !yield(pair.First, pair.Second) {
+				return
+			}
+		}
+	}
+}
+
+// Enumerate exposes an Iterator as an iter.Seq2 of positions and elements
+func Enumerate[T any](it Iterator[T]) iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		index := 0
+		for item, ok := it.Next(); ok; item, ok = it.Next() {
+			if !yield(index, item) {
+				return
+			}
+			index++
+		}
+	}
+}
+
+// PullIterator is an Iterator backed by a push-style iter.Seq
+// Stop must be called if the iterator is abandoned before it is exhausted,
+// otherwise the goroutine running the sequence is leaked.
+type PullIterator[T any] struct {
+	next func() (T, bool)
+	stop func()
+}
+
+// FromSeq converts an iter.Seq into a pull-based Iterator
+func FromSeq[T any](seq iter.Seq[T]) *PullIterator[T] {
+	next, stop := iter.Pull(seq)
+	return &PullIterator[T]{next: next, stop: stop}
+}
+
+// FromSeq2 converts an iter.Seq2 into a pull-based Iterator of pairs
+func FromSeq2[K, V any](seq iter.Seq2[K, V]) *PullIterator[Pair[K, V]] {
+	next, stop := iter.Pull2(seq)
+	return &PullIterator[Pair[K, V]]{
+		next: func() (Pair[K, V], bool) {
+			k, v, ok := next()
+			return Pair[K, V]{First: k, Second: v}, ok
+		},
+		stop: stop,
+	}
+}
+
+// Next returns the next element of the sequence
+func (p *PullIterator[T]) Next() (T, bool) {
+	return p.next()
+}
+
+// Stop releases the sequence; Next reports false afterwards
+func (p *PullIterator[T]) Stop() {
+	p.stop()
+}
+
+// All returns the remaining books as an iter.Seq for use in range loops
+func (b *BookIterator) All() iter.Seq[*Book] {
+	return Seq[*Book](b)
+}
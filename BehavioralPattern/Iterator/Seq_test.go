@@ -0,0 +1,114 @@
+package main
+
+import (
+	"iter"
+	"testing"
+)
+
+func TestSeqBreakLeavesRemainingElements(t *testing.T) {
+	it := FromSlice([]int{1, 2, 3, 4})
+	var seen []int
+	for item := range Seq(it) {
+		seen = append(seen, item)
+		if item == 2 {
+			break
+		}
+	}
+	expectItems(t, seen, []int{1, 2})
+	expectItems(t, Collect(it), []int{3, 4})
+}
+
+func TestSeq2Break(t *testing.T) {
+	it := Zip(FromSlice([]int{1, 2, 3}), FromSlice([]string{"a", "b", "c"}))
+	var keys []int
+	var values []string
+	for k, v := range Seq2(it) {
+		keys = append(keys, k)
+		values = append(values, v)
+		if k == 2 {
+			break
+		}
+	}
+	expectItems(t, keys, []int{1, 2})
+	expectItems(t, values, []string{"a", "b"})
+	expectItems(t, Collect(it), []Pair[int, string]{{3, "c"}})
+}
+
+func TestEnumerate(t *testing.T) {
+	it := FromSlice([]string{"a", "b", "c"})
+	var indexes []int
+	var items []string
+	for i, item := range Enumerate(it) {
+		if i == 2 {
+			break
+		}
+		indexes = append(indexes, i)
+		items = append(items, item)
+	}
+	expectItems(t, indexes, []int{0, 1})
+	expectItems(t, items, []string{"a", "b"})
+}
+
+// countingSeq yields 1..n and records whether the sequence function returned
+func countingSeq(n int, finished *bool) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		defer func() { *finished = true }()
+		for i := 1; i <= n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestFromSeq(t *testing.T) {
+	var finished bool
+	it := FromSeq(countingSeq(3, &finished))
+	defer it.Stop()
+	expectItems(t, Collect[int](it), []int{1, 2, 3})
+	if !finished {
+		t.Fatal("sequence did not finish after being exhausted")
+	}
+}
+
+func TestFromSeqStopReleasesSequence(t *testing.T) {
+	var finished bool
+	it := FromSeq(countingSeq(100, &finished))
+	if item, ok := it.Next(); !ok || item != 1 {
+		t.Fatalf("Next = %d, %v", item, ok)
+	}
+	if finished {
+		t.Fatal("sequence finished before Stop")
+	}
+	it.Stop()
+	if !finished {
+		t.Fatal("Stop did not release the sequence")
+	}
+	if _, ok := it.Next(); ok {
+		t.Fatal("Next after Stop returned a value")
+	}
+	it.Stop() // Stopping twice is harmless
+}
+
+func TestFromSeq2StopReleasesSequence(t *testing.T) {
+	var finished bool
+	seq := func(yield func(string, int) bool) {
+		defer func() { finished = true }()
+		for i, key := range []string{"a", "b", "c"} {
+			if !yield(key, i) {
+				return
+			}
+		}
+	}
+	it := FromSeq2(seq)
+	if pair, ok := it.Next(); !ok || pair != (Pair[string, int]{"a", 0}) {
+		t.Fatalf("Next = %v, %v", pair, ok)
+	}
+	it.Stop()
+	if !finished {
+		t.Fatal("Stop did not release the sequence")
+	}
+	if _, ok := it.Next(); ok {
+		t.Fatal("Next after Stop returned a value")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrConcurrentModification is reported by a fail-fast iterator whose shelf changed
+var ErrConcurrentModification = errors.New("bookshelf: concurrent modification")
+
+// BookShelf is a mutable book collection that is safe for concurrent use
+// The books slice is copy-on-write: mutations always build a new slice, so a
+// snapshot can keep reading the old one without locking. The modification
+// counter lets fail-fast iterators detect changes made after they were created.
+type BookShelf struct {
+	mu       sync.RWMutex
+	books    []*Book // Never modified in place once published
+	modCount uint64  // Incremented on every mutation
+}
+
+// NewBookShelf creates a shelf holding the given books
+func NewBookShelf(books ...*Book) *BookShelf {
+	return &BookShelf{books: append([]*Book(nil), books...)}
+}
+
+// Add puts a book at the end of the shelf
+func (s *BookShelf) Add(book *Book) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The full slice expression forces append to copy instead of growing in place
+	s.books = append(s.books[:len(s.books):len(s.books)], book)
+	s.modCount++
+}
+
+// Remove takes the book at index off the shelf
+func (s *BookShelf) Remove(index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index < 0 || index >= len(s.books) {
+		return fmt.Errorf("bookshelf: index %d out of range", index)
+	}
+	books := make([]*Book, 0, len(s.books)-1)
+	books = append(books, s.books[:index]...)
+	s.books = append(books, s.books[index+1:]...)
+	s.modCount++
+	return nil
+}
+
+// Len returns the number of books on the shelf
+func (s *BookShelf) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.books)
+}
+
+// Iterator returns a fail-fast iterator that stops with ErrConcurrentModification
+// as soon as the shelf is modified
+func (s *BookShelf) Iterator() *FailFastIterator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &FailFastIterator{shelf: s, expected: s.modCount}
+}
+
+// Snapshot returns an iterator over the books on the shelf right now
+// Later modifications of the shelf are not visible to it
+func (s *BookShelf) Snapshot() Iterator[*Book] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return FromSlice(s.books)
+}
+
+// FailFastIterator iterates over a BookShelf and fails if the shelf changes
+type FailFastIterator struct {
+	shelf    *BookShelf
+	expected uint64 // Modification count when the iterator was created
+	position int
+	err      error
+}
+
+// Next returns the next book, or false at the end or after a modification
+func (f *FailFastIterator) Next() (*Book, bool) {
+	if f.err != nil {
+		return nil, false
+	}
+	f.shelf.mu.RLock()
+	defer f.shelf.mu.RUnlock()
+	if f.shelf.modCount != f.expected {
+		f.err = ErrConcurrentModification
+		return nil, false
+	}
+	if f.position >= len(f.shelf.books) {
+		return nil, false
+	}
+	book := f.shelf.books[f.position]
+	f.position++
+	return book, true
+}
+
+// Err returns ErrConcurrentModification if the iteration was cut short
+func (f *FailFastIterator) Err() error {
+	return f.err
+}
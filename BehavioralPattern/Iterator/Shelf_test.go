@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// bookTitles collects the titles yielded by a book iterator
+func bookTitles(it Iterator[*Book]) []string {
+	var out []string
+	for book, ok := it.Next(); ok; book, ok = it.Next() {
+		out = append(out, book.Title)
+	}
+	return out
+}
+
+func newShelf(titles ...string) *BookShelf {
+	books := make([]*Book, len(titles))
+	for i, title := range titles {
+		books[i] = &Book{Title: title}
+	}
+	return NewBookShelf(books...)
+}
+
+func TestFailFastIteratorCompletes(t *testing.T) {
+	it := newShelf("a", "b").Iterator()
+	expectItems(t, bookTitles(it), []string{"a", "b"})
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+}
+
+func TestFailFastIteratorDetectsModification(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(*BookShelf)
+	}{
+		{"add", func(s *BookShelf) { s.Add(&Book{Title: "c"}) }},
+		{"remove", func(s *BookShelf) { s.Remove(0) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			shelf := newShelf("a", "b")
+			it := shelf.Iterator()
+			if book, ok := it.Next(); !ok || book.Title != "a" {
+				t.Fatalf("Next = %v, %v", book, ok)
+			}
+			tc.modify(shelf)
+			if _, ok := it.Next(); ok {
+				t.Fatal("Next succeeded after the shelf was modified")
+			}
+			if !errors.Is(it.Err(), ErrConcurrentModification) {
+				t.Fatalf("Err = %v, want ErrConcurrentModification", it.Err())
+			}
+			// The failure is sticky
+			if _, ok := it.Next(); ok {
+				t.Fatal("Next succeeded after a failure")
+			}
+		})
+	}
+}
+
+func TestFailedRemoveIsNotAModification(t *testing.T) {
+	shelf := newShelf("a")
+	it := shelf.Iterator()
+	if err := shelf.Remove(5); err == nil {
+		t.Fatal("Remove out of range succeeded")
+	}
+	expectItems(t, bookTitles(it), []string{"a"})
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	shelf := newShelf("a", "b", "c")
+	snapshot := shelf.Snapshot()
+	shelf.Add(&Book{Title: "d"})
+	shelf.Remove(0)
+	expectItems(t, bookTitles(snapshot), []string{"a", "b", "c"})
+	expectItems(t, bookTitles(shelf.Snapshot()), []string{"b", "c", "d"})
+}
+
+func TestShelfConcurrentReadersAndWriters(t *testing.T) {
+	shelf := newShelf("a", "b", "c")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				shelf.Add(&Book{Title: "new"})
+				shelf.Remove(0)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if n := len(bookTitles(shelf.Snapshot())); n < 3 {
+					t.Errorf("snapshot has %d books, want at least 3", n)
+					return
+				}
+				it := shelf.Iterator()
+				bookTitles(it)
+				if err := it.Err(); err != nil && !errors.Is(err, ErrConcurrentModification) {
+					t.Errorf("unexpected error %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if shelf.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", shelf.Len())
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeError reports a record that could not be decoded and where it was
+type DecodeError struct {
+	Line int   // 1-based line number in the input
+	Err  error // Underlying error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// CSVIterator decodes CSV records into structs one at a time
+// The first record is the header; each column is stored in the struct field whose
+// `csv` tag (or name, case-insensitively) matches the column name. Unknown columns
+// are ignored. T must be a struct type; Next returns a pointer to a new value.
+type CSVIterator[T any] struct {
+	reader  *csv.Reader
+	columns []int // Struct field index for each column, -1 when unmapped
+	err     error
+}
+
+// NewCSVIterator reads the header from r and prepares the column mapping
+func NewCSVIterator[T any](r io.Reader) (*CSVIterator[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("csv: %s is not a struct", t)
+	}
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, &DecodeError{Line: 1, Err: err}
+	}
+
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("csv")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = i
+	}
+	columns := make([]int, len(header))
+	for i, name := range header {
+		index, ok := fields[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			index = -1
+		}
+		columns[i] = index
+	}
+	return &CSVIterator[T]{reader: reader, columns: columns}, nil
+}
+
+// Next decodes the next record
+func (c *CSVIterator[T]) Next() (*T, bool) {
+	if c.err != nil {
+		return nil, false
+	}
+	record, err := c.reader.Read()
+	if err == io.EOF {
+		return nil, false
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		line := 0
+		if errors.As(err, &parseErr) {
+			line = parseErr.Line
+		}
+		c.err = &DecodeError{Line: line, Err: err}
+		return nil, false
+	}
+	line, _ := c.reader.FieldPos(0)
+
+	item := new(T)
+	value := reflect.ValueOf(item).Elem()
+	for i, text := range record {
+		if c.columns[i] < 0 {
+			continue
+		}
+		field := value.Field(c.columns[i])
+		if err := setField(field, text); err != nil {
+			c.err = &DecodeError{Line: line, Err: fmt.Errorf("column %d: %w", i+1, err)}
+			return nil, false
+		}
+	}
+	return item, true
+}
+
+// Err returns the error that stopped decoding, or nil at the end of the input
+func (c *CSVIterator[T]) Err() error {
+	return c.err
+}
+
+// setField parses text into a string, bool, integer or float field
+func setField(field reflect.Value, text string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// JSONLinesIterator decodes one JSON value per line
+// Blank lines are skipped.
+type JSONLinesIterator[T any] struct {
+	scanner *bufio.Scanner
+	line    int
+	err     error
+}
+
+// NewJSONLinesIterator reads JSON Lines from r
+func NewJSONLinesIterator[T any](r io.Reader) *JSONLinesIterator[T] {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &JSONLinesIterator[T]{scanner: scanner}
+}
+
+// Next decodes the next non-blank line
+func (j *JSONLinesIterator[T]) Next() (*T, bool) {
+	if j.err != nil {
+		return nil, false
+	}
+	for j.scanner.Scan() {
+		j.line++
+		data := bytes.TrimSpace(j.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		item := new(T)
+		if err := json.Unmarshal(data, item); err != nil {
+			j.err = &DecodeError{Line: j.line, Err: err}
+			return nil, false
+		}
+		return item, true
+	}
+	if err := j.scanner.Err(); err != nil {
+		j.err = &DecodeError{Line: j.line + 1, Err: err}
+	}
+	return nil, false
+}
+
+// Err returns the error that stopped decoding, or nil at the end of the input
+func (j *JSONLinesIterator[T]) Err() error {
+	return j.err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// stockRow exercises tags, name matching and ignored fields
+type stockRow struct {
+	Name     string  `csv:"title"`
+	Year     int     // Matched by field name
+	Price    float64 `csv:"price"`
+	InStock  bool    `csv:"in_stock"`
+	Internal string  `csv:"-"`
+	secret   string
+}
+
+// decodeAll drains a CSV iterator
+func decodeAll(t *testing.T, input string) ([]*stockRow, error) {
+	t.Helper()
+	it, err := NewCSVIterator[stockRow](strings.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	var rows []*stockRow
+	for row, ok := it.Next(); ok; row, ok = it.Next() {
+		rows = append(rows, row)
+	}
+	return rows, it.Err()
+}
+
+// decodeErrorLine returns the line of a DecodeError, failing the test for other errors
+func decodeErrorLine(t *testing.T, err error) int {
+	t.Helper()
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("err = %v, want a *DecodeError", err)
+	}
+	return decodeErr.Line
+}
+
+func TestCSVIteratorMapsHeaders(t *testing.T) {
+	input := "TITLE, Year ,price,in_stock,internal,secret,unknown\n" +
+		"设计模式,1994,59.5,true,x,y,z\n" +
+		"重构,1999,0,false,x,y,z\n"
+	rows, err := decodeAll(t, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("decoded %d rows, want 2", len(rows))
+	}
+	want := stockRow{Name: "设计模式", Year: 1994, Price: 59.5, InStock: true}
+	if *rows[0] != want {
+		t.Fatalf("row = %+v, want %+v", *rows[0], want)
+	}
+	if rows[1].Name != "重构" || rows[1].Year != 1999 || rows[1].InStock {
+		t.Fatalf("row = %+v", *rows[1])
+	}
+}
+
+func TestCSVIteratorBadFieldValueLine(t *testing.T) {
+	rows, err := decodeAll(t, "title,year\na,1994\nb,soon\nc,2000\n")
+	if len(rows) != 1 {
+		t.Fatalf("decoded %d rows before the error, want 1", len(rows))
+	}
+	if line := decodeErrorLine(t, err); line != 3 {
+		t.Fatalf("line = %d, want 3", line)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Fatalf("err = %v, want it to wrap a *strconv.NumError", err)
+	}
+	if !strings.Contains(err.Error(), "column 2") {
+		t.Fatalf("err = %v, want the column", err)
+	}
+}
+
+func TestCSVIteratorParseErrorLine(t *testing.T) {
+	// The quoted field spans two lines, so the bad quote is on line 4
+	_, err := decodeAll(t, "title,year\n\"a\nb\",1994\nc\"d,2000\n")
+	if line := decodeErrorLine(t, err); line != 4 {
+		t.Fatalf("line = %d, want 4", line)
+	}
+	var parseErr *csv.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("err = %v, want it to wrap a *csv.ParseError", err)
+	}
+
+	_, err = decodeAll(t, "title,year\na,1994\nb\n")
+	if line := decodeErrorLine(t, err); line != 3 {
+		t.Fatalf("wrong field count: line = %d, want 3", line)
+	}
+}
+
+func TestCSVIteratorHeaderErrors(t *testing.T) {
+	_, err := decodeAll(t, "")
+	if line := decodeErrorLine(t, err); line != 1 || !errors.Is(err, io.EOF) {
+		t.Fatalf("empty input: err = %v", err)
+	}
+	if _, err := NewCSVIterator[string](strings.NewReader("a\n")); err == nil {
+		t.Fatal("NewCSVIterator accepted a non-struct type")
+	}
+}
+
+func TestJSONLinesIterator(t *testing.T) {
+	input := `{"title": "a"}` + "\n\n   \n" + `{"title": "b"}` + "\n"
+	it := NewJSONLinesIterator[Book](strings.NewReader(input))
+	var got []string
+	for book, ok := it.Next(); ok; book, ok = it.Next() {
+		got = append(got, book.Title)
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	expectItems(t, got, []string{"a", "b"})
+}
+
+func TestJSONLinesIteratorErrorLineCountsBlankLines(t *testing.T) {
+	input := `{"title": "a"}` + "\n\n" + `{"title": ` + "\n"
+	it := NewJSONLinesIterator[Book](strings.NewReader(input))
+	if _, ok := it.Next(); !ok {
+		t.Fatal("first line did not decode")
+	}
+	if _, ok := it.Next(); ok {
+		t.Fatal("bad line decoded")
+	}
+	if line := decodeErrorLine(t, it.Err()); line != 3 {
+		t.Fatalf("line = %d, want 3", line)
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(it.Err(), &syntaxErr) {
+		t.Fatalf("err = %v, want it to wrap a *json.SyntaxError", it.Err())
+	}
+	if _, ok := it.Next(); ok {
+		t.Fatal("Next succeeded after an error")
+	}
+}
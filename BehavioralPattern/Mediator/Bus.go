@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrNoHandler is returned by Send when no handler is registered for the request type
+var ErrNoHandler = errors.New("bus: no handler registered")
+
+// Next continues the pipeline with the next behavior or the handler itself
+type Next func(ctx context.Context, msg any) (any, error)
+
+// Behavior wraps every handler invocation, for requests and notifications alike
+type Behavior func(ctx context.Context, msg any, next Next) (any, error)
+
+// Bus is an in-process mediator for typed requests and notifications
+// Services register handlers with the bus instead of calling each other directly.
+type Bus struct {
+	mu          sync.RWMutex
+	handlers    map[reflect.Type]requestHandler // Request type -> single handler
+	subscribers map[reflect.Type][]Next         // Notification type -> handlers
+	behaviors   []Behavior                      // Outermost first
+}
+
+// requestHandler is a registered request handler and the response type it returns
+type requestHandler struct {
+	resp   reflect.Type
+	handle Next
+}
+
+// NewBus creates a bus without handlers
+func NewBus() *Bus {
+	return &Bus{
+		handlers:    map[reflect.Type]requestHandler{},
+		subscribers: map[reflect.Type][]Next{},
+	}
+}
+
+// Use appends behaviors to the pipeline; earlier behaviors wrap later ones
+func (b *Bus) Use(behaviors ...Behavior) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.behaviors = append(b.behaviors, behaviors...)
+}
+
+// typeOf returns the dynamic type of T, including interface types
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Handle registers the handler for requests of type Req
+// Each request type has exactly one handler.
+func Handle[Req, Resp any](b *Bus, handler func(ctx context.Context, req Req) (Resp, error)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	reqType := typeOf[Req]()
+	if _, ok := b.handlers[reqType]; ok {
+		return fmt.Errorf("bus: handler for %v already registered", reqType)
+	}
+	b.handlers[reqType] = requestHandler{
+		resp: typeOf[Resp](),
+		handle: func(ctx context.Context, msg any) (any, error) {
+			return handler(ctx, msg.(Req))
+		},
+	}
+	return nil
+}
+
+// Send dispatches req to its handler through the pipeline behaviors
+// A handler whose response type is not assignable to Resp is rejected before it runs.
+func Send[Req, Resp any](ctx context.Context, b *Bus, req Req) (Resp, error) {
+	var zero Resp
+	b.mu.RLock()
+	handler, ok := b.handlers[typeOf[Req]()]
+	behaviors := b.behaviors
+	b.mu.RUnlock()
+	if !ok {
+		return zero, fmt.Errorf("%w for %v", ErrNoHandler, typeOf[Req]())
+	}
+	if !handler.resp.AssignableTo(typeOf[Resp]()) {
+		return zero, fmt.Errorf("bus: handler for %v returns %v, not %v", typeOf[Req](), handler.resp, typeOf[Resp]())
+	}
+
+	result, err := pipeline(behaviors, handler.handle)(ctx, req)
+	if err != nil {
+		return zero, err
+	}
+	resp, ok := result.(Resp)
+	if !ok && result != nil {
+		return zero, fmt.Errorf("bus: handler for %v returned %T, not %v", typeOf[Req](), result, typeOf[Resp]())
+	}
+	return resp, nil
+}
+
+// Subscribe adds a handler for notifications of type N
+func Subscribe[N any](b *Bus, handler func(ctx context.Context, n N) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	nType := typeOf[N]()
+	b.subscribers[nType] = append(b.subscribers[nType], func(ctx context.Context, msg any) (any, error) {
+		return nil, handler(ctx, msg.(N))
+	})
+}
+
+// Publish delivers n to every subscriber in registration order
+// A failing subscriber does not stop the others; all errors are joined.
+func Publish[N any](ctx context.Context, b *Bus, n N) error {
+	b.mu.RLock()
+	subscribers := b.subscribers[typeOf[N]()]
+	behaviors := b.behaviors
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, subscriber := range subscribers {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if _, err := pipeline(behaviors, subscriber)(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pipeline wraps handler with the behaviors, the first behavior outermost
+func pipeline(behaviors []Behavior, handler Next) Next {
+	for i := len(behaviors) - 1; i >= 0; i-- {
+		behavior, next := behaviors[i], handler
+		handler = func(ctx context.Context, msg any) (any, error) {
+			return behavior(ctx, msg, next)
+		}
+	}
+	return handler
+}
+
+// Validator is implemented by messages that can check themselves
+type Validator interface {
+	Validate() error
+}
+
+// ValidationBehavior rejects messages whose Validate method fails
+func ValidationBehavior() Behavior {
+	return func(ctx context.Context, msg any, next Next) (any, error) {
+		if v, ok := msg.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, fmt.Errorf("bus: invalid %T: %w", msg, err)
+			}
+		}
+		return next(ctx, msg)
+	}
+}
+
+// LoggingBehavior logs every message and its outcome
+func LoggingBehavior(logf func(format string, args ...any)) Behavior {
+	return func(ctx context.Context, msg any, next Next) (any, error) {
+		logf("处理 %T", msg) // Handling
+		result, err := next(ctx, msg)
+		if err != nil {
+			logf("%T 失败: %v", msg, err) // Failed
+		}
+		return result, err
+	}
+}
+
+// TimingBehavior reports how long each handler took
+func TimingBehavior(observe func(msg any, d time.Duration)) Behavior {
+	return func(ctx context.Context, msg any, next Next) (any, error) {
+		start := time.Now()
+		result, err := next(ctx, msg)
+		observe(msg, time.Since(start))
+		return result, err
+	}
+}
+
+// PlaceOrder is an example request handled by the order service
+type PlaceOrder struct {
+	Item     string
+	Quantity int
+}
+
+// Validate checks the order before it reaches the handler
+func (p PlaceOrder) Validate() error {
+	if p.Item == "" || p.Quantity <= 0 {
+		return errors.New("item and a positive quantity are required")
+	}
+	return nil
+}
+
+// OrderPlaced is an example notification published by the order service
+type OrderPlaced struct {
+	OrderID string
+	Item    string
+}
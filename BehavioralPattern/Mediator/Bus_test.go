@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSendDispatchesToHandler(t *testing.T) {
+	bus := NewBus()
+	if err := Handle(bus, func(ctx context.Context, req PlaceOrder) (string, error) {
+		return fmt.Sprintf("%s x%d", req.Item, req.Quantity), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Send[PlaceOrder, string](context.Background(), bus, PlaceOrder{Item: "键盘", Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "键盘 x2" {
+		t.Fatalf("Send = %q, want %q", got, "键盘 x2")
+	}
+}
+
+func TestSendWithoutHandler(t *testing.T) {
+	bus := NewBus()
+	_, err := Send[PlaceOrder, string](context.Background(), bus, PlaceOrder{Item: "键盘", Quantity: 1})
+	if !errors.Is(err, ErrNoHandler) {
+		t.Fatalf("err = %v, want ErrNoHandler", err)
+	}
+}
+
+func TestHandleRejectsDuplicate(t *testing.T) {
+	bus := NewBus()
+	handler := func(ctx context.Context, req PlaceOrder) (string, error) { return "first", nil }
+	if err := Handle(bus, handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := Handle(bus, func(ctx context.Context, req PlaceOrder) (string, error) { return "second", nil }); err == nil {
+		t.Fatal("second handler for the same request type should be rejected")
+	}
+	got, err := Send[PlaceOrder, string](context.Background(), bus, PlaceOrder{Item: "键盘", Quantity: 1})
+	if err != nil || got != "first" {
+		t.Fatalf("Send = %q, %v, want the first handler", got, err)
+	}
+}
+
+func TestSendChecksResponseTypeBeforeRunning(t *testing.T) {
+	bus := NewBus()
+	calls, published := 0, 0
+	Subscribe(bus, func(ctx context.Context, n OrderPlaced) error {
+		published++
+		return nil
+	})
+	Handle(bus, func(ctx context.Context, req PlaceOrder) (string, error) {
+		calls++
+		return "A-1", Publish(ctx, bus, OrderPlaced{OrderID: "A-1", Item: req.Item})
+	})
+
+	_, err := Send[PlaceOrder, int](context.Background(), bus, PlaceOrder{Item: "键盘", Quantity: 1})
+	if err == nil {
+		t.Fatal("Send with the wrong response type should fail")
+	}
+	if calls != 0 || published != 0 {
+		t.Fatalf("handler ran %d times and published %d times, want no side effects", calls, published)
+	}
+}
+
+func TestSendAcceptsAssignableResponse(t *testing.T) {
+	bus := NewBus()
+	Handle(bus, func(ctx context.Context, req PlaceOrder) (string, error) { return "A-1", nil })
+	got, err := Send[PlaceOrder, fmt.Stringer](context.Background(), bus, PlaceOrder{Item: "键盘", Quantity: 1})
+	if err == nil {
+		t.Fatalf("string does not implement fmt.Stringer, got %v", got)
+	}
+	anything, err := Send[PlaceOrder, any](context.Background(), bus, PlaceOrder{Item: "键盘", Quantity: 1})
+	if err != nil || anything != "A-1" {
+		t.Fatalf("Send[any] = %v, %v, want A-1", anything, err)
+	}
+}
+
+func TestPublishFansOutAndJoinsErrors(t *testing.T) {
+	bus := NewBus()
+	errFirst, errThird := errors.New("first"), errors.New("third")
+	var seen []int
+	for i, err := range []error{errFirst, nil, errThird} {
+		Subscribe(bus, func(ctx context.Context, n OrderPlaced) error {
+			seen = append(seen, i)
+			return err
+		})
+	}
+
+	err := Publish(context.Background(), bus, OrderPlaced{OrderID: "A-1"})
+	if !reflect.DeepEqual(seen, []int{0, 1, 2}) {
+		t.Fatalf("subscribers ran %v, want every subscriber in order", seen)
+	}
+	if !errors.Is(err, errFirst) || !errors.Is(err, errThird) {
+		t.Fatalf("err = %v, want both subscriber errors", err)
+	}
+	if err := Publish(context.Background(), bus, PlaceOrder{}); err != nil {
+		t.Fatalf("publish without subscribers = %v, want nil", err)
+	}
+}
+
+func TestPublishStopsWhenCancelled(t *testing.T) {
+	bus := NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	Subscribe(bus, func(ctx context.Context, n OrderPlaced) error {
+		calls++
+		cancel()
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, n OrderPlaced) error {
+		calls++
+		return nil
+	})
+	if err := Publish(ctx, bus, OrderPlaced{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Fatalf("%d subscribers ran, want 1", calls)
+	}
+}
+
+func TestBehaviorsWrapInOrder(t *testing.T) {
+	bus := NewBus()
+	var trace []string
+	record := func(name string) Behavior {
+		return func(ctx context.Context, msg any, next Next) (any, error) {
+			trace = append(trace, name+" 前")
+			result, err := next(ctx, msg)
+			trace = append(trace, name+" 后")
+			return result, err
+		}
+	}
+	bus.Use(record("外层"))
+	bus.Use(record("内层"))
+	Handle(bus, func(ctx context.Context, req PlaceOrder) (string, error) {
+		trace = append(trace, "处理")
+		return "A-1", nil
+	})
+	Subscribe(bus, func(ctx context.Context, n OrderPlaced) error {
+		trace = append(trace, "通知")
+		return nil
+	})
+
+	if _, err := Send[PlaceOrder, string](context.Background(), bus, PlaceOrder{Item: "键盘", Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"外层 前", "内层 前", "处理", "内层 后", "外层 后"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Fatalf("trace = %v, want %v", trace, want)
+	}
+
+	trace = nil
+	if err := Publish(context.Background(), bus, OrderPlaced{}); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"外层 前", "内层 前", "通知", "内层 后", "外层 后"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Fatalf("trace = %v, want %v", trace, want)
+	}
+}
+
+func TestValidationBehaviorStopsInvalidRequests(t *testing.T) {
+	bus := NewBus()
+	bus.Use(ValidationBehavior())
+	calls := 0
+	Handle(bus, func(ctx context.Context, req PlaceOrder) (string, error) {
+		calls++
+		return "A-1", nil
+	})
+	if _, err := Send[PlaceOrder, string](context.Background(), bus, PlaceOrder{Item: "键盘"}); err == nil {
+		t.Fatal("an order without quantity should fail validation")
+	}
+	if calls != 0 {
+		t.Fatal("handler should not run for an invalid request")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a chat message kept in the room history
+type Message struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	From string    `json:"from"` // Sender ID
+	Name string    `json:"name"` // Sender name at the time of sending
+	Text string    `json:"text"`
+}
+
+// Query selects messages from the history; zero fields match everything
+type Query struct {
+	Keyword string    // Case-insensitive substring of the text
+	From    string    // Sender ID or name
+	Since   time.Time // Inclusive lower bound
+	Until   time.Time // Exclusive upper bound
+}
+
+// Match reports whether msg satisfies every condition of the query
+func (q Query) Match(msg Message) bool {
+	if q.Keyword != "" && !strings.Contains(strings.ToLower(msg.Text), strings.ToLower(q.Keyword)) {
+		return false
+	}
+	if q.From != "" && q.From != msg.From && q.From != msg.Name {
+		return false
+	}
+	if !q.Since.IsZero() && msg.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !msg.Time.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// History keeps the most recent messages of a room in a ring buffer
+// When backed by a file every message is also appended to it as a JSON line,
+// so searches can reach messages that have already left the buffer.
+type History struct {
+	mu    sync.Mutex
+	ring  []Message
+	start int // Index of the oldest message
+	size  int // Number of messages in the ring
+	path  string
+	file  *os.File
+	err   error // First error writing to the file
+}
+
+// NewHistory creates an in-memory history holding up to capacity messages
+func NewHistory(capacity int) *History {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &History{ring: make([]Message, capacity)}
+}
+
+// OpenHistory creates a history backed by an append-only file
+// Messages already in the file are loaded into the ring buffer. A partial last
+// line left by a crash is truncated away so new messages are not appended to it.
+func OpenHistory(path string, capacity int) (*History, error) {
+	h := NewHistory(capacity)
+	h.path = path
+	good, err := scanHistory(path, func(msg Message) {
+		h.push(msg)
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err == nil && info.Size() > good {
+		if err = file.Truncate(good); err == nil {
+			err = file.Sync()
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	h.file = file
+	return h, nil
+}
+
+// scanHistory calls fn for every complete message line in the file and returns
+// the offset just past the last complete line. Complete lines that do not parse
+// are skipped; an unterminated last line is a partial write and is ignored.
+func scanHistory(path string, fn func(Message)) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	var good int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return good, nil
+		}
+		if err != nil {
+			return good, err
+		}
+		good += int64(len(line))
+		var msg Message
+		if json.Unmarshal(line, &msg) == nil {
+			fn(msg)
+		}
+	}
+}
+
+// push adds msg to the ring, evicting the oldest message when full
+// The caller must hold h.mu
+func (h *History) push(msg Message) {
+	if h.size < len(h.ring) {
+		h.ring[(h.start+h.size)%len(h.ring)] = msg
+		h.size++
+		return
+	}
+	h.ring[h.start] = msg
+	h.start = (h.start + 1) % len(h.ring)
+}
+
+// Append records a message
+// The message is always kept in memory; a failed file write is returned and
+// remembered for Err.
+func (h *History) Append(msg Message) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.push(msg)
+	if h.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(msg)
+	if err == nil {
+		_, err = h.file.Write(append(line, '\n'))
+	}
+	if err != nil && h.err == nil {
+		h.err = err
+	}
+	return err
+}
+
+// Last returns up to n of the most recent messages, oldest first
+func (h *History) Last(n int) []Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n > h.size {
+		n = h.size
+	}
+	if n <= 0 {
+		return nil
+	}
+	messages := make([]Message, n)
+	for i := range messages {
+		messages[i] = h.ring[(h.start+h.size-n+i)%len(h.ring)]
+	}
+	return messages
+}
+
+// Len returns the number of messages in the ring buffer
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.size
+}
+
+// Search returns the messages matching q, oldest first
+// File-backed histories search the whole file, others only the ring buffer.
+func (h *History) Search(q Query) ([]Message, error) {
+	var found []Message
+	if h.path != "" {
+		_, err := scanHistory(h.path, func(msg Message) {
+			if q.Match(msg) {
+				found = append(found, msg)
+			}
+		})
+		return found, err
+	}
+	for _, msg := range h.Last(len(h.ring)) {
+		if q.Match(msg) {
+			found = append(found, msg)
+		}
+	}
+	return found, nil
+}
+
+// Err returns the first error writing to the history file
+func (h *History) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Close closes the history file, if any
+func (h *History) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file == nil {
+		return nil
+	}
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var historyStart = time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+// chatMessage builds a message sent n minutes after historyStart
+func chatMessage(n int, from, name, text string) Message {
+	return Message{
+		ID:   "m" + string(rune('0'+n)),
+		Time: historyStart.Add(time.Duration(n) * time.Minute),
+		From: from,
+		Name: name,
+		Text: text,
+	}
+}
+
+// messageIDs returns the IDs of the messages in order
+func messageIDs(messages []Message) []string {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func expectIDs(t *testing.T, messages []Message, want ...string) {
+	t.Helper()
+	if got := messageIDs(messages); !slices.Equal(got, want) {
+		t.Fatalf("messages = %v, want %v", got, want)
+	}
+}
+
+func TestHistoryRingEviction(t *testing.T) {
+	history := NewHistory(3)
+	for i := 1; i <= 5; i++ {
+		history.Append(chatMessage(i, "u1", "张三", "hi"))
+	}
+	if history.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", history.Len())
+	}
+	expectIDs(t, history.Last(10), "m3", "m4", "m5")
+	expectIDs(t, history.Last(2), "m4", "m5")
+	if got := history.Last(0); len(got) != 0 {
+		t.Fatalf("Last(0) = %v", got)
+	}
+	if got := NewHistory(3).Last(2); len(got) != 0 {
+		t.Fatalf("empty history Last = %v", got)
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	history := NewHistory(10)
+	history.Append(chatMessage(1, "u1", "张三", "今天有龙井"))
+	history.Append(chatMessage(2, "u2", "李四", "LONGJING tea"))
+	history.Append(chatMessage(3, "u1", "张三", "longjing again"))
+	history.Append(chatMessage(4, "u2", "李四", "再见"))
+
+	for _, tc := range []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"everything", Query{}, []string{"m1", "m2", "m3", "m4"}},
+		{"keyword ignores case", Query{Keyword: "LongJing"}, []string{"m2", "m3"}},
+		{"sender ID", Query{From: "u1"}, []string{"m1", "m3"}},
+		{"sender name", Query{From: "李四"}, []string{"m2", "m4"}},
+		{"since is inclusive", Query{Since: historyStart.Add(3 * time.Minute)}, []string{"m3", "m4"}},
+		{"until is exclusive", Query{Until: historyStart.Add(3 * time.Minute)}, []string{"m1", "m2"}},
+		{"combined", Query{Keyword: "longjing", From: "u1", Since: historyStart.Add(2 * time.Minute)}, []string{"m3"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := history.Search(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectIDs(t, found, tc.want...)
+		})
+	}
+}
+
+func TestHistoryFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, err := OpenHistory(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		history.Append(chatMessage(i, "u1", "张三", "hi"))
+	}
+	history.Close()
+
+	reopened, err := OpenHistory(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	expectIDs(t, reopened.Last(10), "m2", "m3")
+	// Searches reach messages that already left the ring
+	found, _ := reopened.Search(Query{})
+	expectIDs(t, found, "m1", "m2", "m3")
+}
+
+func TestHistoryTruncatesTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, _ := OpenHistory(path, 10)
+	history.Append(chatMessage(1, "u1", "张三", "hi"))
+	history.Close()
+	// Simulate a crash halfway through writing m2
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	file.WriteString(`{"id":"m2","text":"hal`)
+	file.Close()
+
+	history, err := OpenHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectIDs(t, history.Last(10), "m1")
+	if err := history.Append(chatMessage(3, "u1", "张三", "after the crash")); err != nil {
+		t.Fatal(err)
+	}
+	history.Close()
+
+	reopened, err := OpenHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	expectIDs(t, reopened.Last(10), "m1", "m3")
+}
+
+func TestHistorySkipsDamagedCompleteLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	os.WriteFile(path, []byte(`{"id":"m1"}`+"\nnot json\n"+`{"id":"m2"}`+"\n"), 0o644)
+	history, err := OpenHistory(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	expectIDs(t, history.Last(10), "m1", "m2")
+}
+
+func TestReplayOnRegister(t *testing.T) {
+	room := NewChatRoom("茶馆")
+	room.History = NewHistory(10)
+	room.Replay = 2
+	room.Now = func() time.Time { return historyStart }
+	defer room.Close()
+	host := newRecorder("老板", room)
+	room.Register(host)
+	for _, text := range []string{"早", "今天有龙井", "龙井五折"} {
+		if _, err := room.Post(host, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	guest := newRecorder("客人", room)
+	room.Register(guest)
+	room.Flush()
+	got := guest.received()
+	if len(got) != 2 {
+		t.Fatalf("guest received %q, want the last 2 messages", got)
+	}
+	for i, text := range []string{"今天有龙井", "龙井五折"} {
+		if want := "[历史 09:00] 老板: " + text; got[i] != want {
+			t.Fatalf("replay %d = %q, want %q", i, got[i], want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults used by a ChatRoom whose delivery settings are left at zero
+const (
+	DefaultInboxSize    = 64
+	DefaultBlockTimeout = time.Second
+)
+
+// SlowConsumerPolicy decides what happens when a member's inbox is full
+type SlowConsumerPolicy int
+
+const (
+	DropOldest       SlowConsumerPolicy = iota // Discard the oldest queued message to make room
+	Disconnect                                 // Remove the member from the room
+	BlockWithTimeout                           // Wait for room, then drop the new message
+)
+
+// envelope is an inbox entry: a message, or a flush marker when ack is set
+type envelope struct {
+	msg     string
+	message *Message // Chat message with its ID, for members implementing MessageReceiver
+	ack     chan struct{}
+}
+
+// inbox is a bounded queue of messages for one member, drained by its own goroutine
+// so a slow member never holds up the sender or the other members.
+type inbox struct {
+	user Obj
+	ch   chan envelope
+	done chan struct{} // Closed when the delivery goroutine exits
+
+	mu     sync.Mutex // Serializes senders so closing never races with a send
+	closed bool
+
+	// Presence state, guarded by the room's mutex
+	lastSeen time.Time
+	presence Presence // Online until CheckPresence finds the member silent
+	typing   bool
+}
+
+// newInbox creates an inbox and starts delivering to user
+func newInbox(user Obj, size int) *inbox {
+	in := &inbox{
+		user: user,
+		ch:   make(chan envelope, size),
+		done: make(chan struct{}),
+	}
+	go in.run()
+	return in
+}
+
+// run delivers queued messages in order until the inbox is closed and empty
+func (in *inbox) run() {
+	defer close(in.done)
+	for env := range in.ch {
+		if env.ack != nil {
+			close(env.ack)
+			continue
+		}
+		if receiver, ok := in.user.(MessageReceiver); ok && env.message != nil {
+			receiver.RevMessage(*env.message)
+			continue
+		}
+		in.user.RevMsg(env.msg)
+	}
+}
+
+// offer queues env according to policy
+// It reports whether a message was dropped and whether the member should be disconnected.
+func (in *inbox) offer(env envelope, policy SlowConsumerPolicy, timeout time.Duration) (dropped, disconnect bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed {
+		return false, false
+	}
+	select {
+	case in.ch <- env:
+		return false, false
+	default:
+	}
+
+	switch policy {
+	case Disconnect:
+		return true, true
+	case BlockWithTimeout:
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case in.ch <- env:
+			return false, false
+		case <-timer.C:
+			return true, false
+		}
+	default: // DropOldest
+		select {
+		case old := <-in.ch:
+			if old.ack != nil {
+				// Never lose a flush marker, release its waiter instead
+				close(old.ack)
+			} else {
+				dropped = true
+			}
+		default:
+		}
+		// Only senders holding in.mu add entries, so a slot is free now
+		select {
+		case in.ch <- env:
+		default:
+			dropped = true
+		}
+		return dropped, false
+	}
+}
+
+// flush waits until everything queued before the call has been delivered
+func (in *inbox) flush() {
+	in.mu.Lock()
+	if in.closed {
+		in.mu.Unlock()
+		<-in.done
+		return
+	}
+	ack := make(chan struct{})
+	in.ch <- envelope{ack: ack}
+	in.mu.Unlock()
+	<-ack
+}
+
+// close stops accepting messages; already queued messages are still delivered
+func (in *inbox) close() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if !in.closed {
+		in.closed = true
+		close(in.ch)
+	}
+}
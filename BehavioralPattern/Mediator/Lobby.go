@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Lobby is a mediator that manages many chat rooms and routes private messages
+// Users register with the lobby once and can then join any number of rooms.
+// It is safe for concurrent use. Registered users are members of an internal
+// hall room, so lobby-wide messages get the same inboxes and slow-consumer
+// handling as room messages.
+type Lobby struct {
+	hall *ChatRoom // Every registered user, used for lobby-wide delivery
+
+	mu    sync.RWMutex
+	rooms map[string]*ChatRoom // Rooms by name
+}
+
+// NewLobby creates an empty lobby whose messages pass through filters
+// Mutes and bans issued by a room admin only apply inside that room, so they
+// never affect lobby announcements or private messages.
+func NewLobby(filters ...Filter) *Lobby {
+	hall := NewChatRoom("大厅") // Hall
+	hall.Filters = filters
+	return &Lobby{
+		hall:  hall,
+		rooms: map[string]*ChatRoom{},
+	}
+}
+
+// Register connects a user to the lobby
+func (l *Lobby) Register(user Obj) error {
+	return l.hall.Register(user)
+}
+
+// Unregister disconnects a user and removes them from every room
+func (l *Lobby) Unregister(user Obj) {
+	for _, room := range l.roomList() {
+		room.Leave(user)
+	}
+	l.hall.Unregister(user)
+}
+
+// CreateRoom creates a room owned by owner, who becomes its admin and first member
+func (l *Lobby) CreateRoom(name string, owner Obj) (*ChatRoom, error) {
+	if owner.GetID() == "" {
+		return nil, ErrNoUserID
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.rooms[name]; ok {
+		return nil, fmt.Errorf("lobby: room %s already exists", name)
+	}
+	room := NewChatRoom(name)
+	room.AddAdmin(owner.GetID())
+	room.Register(owner)
+	l.rooms[name] = room
+	return room, nil
+}
+
+// Room returns the room with the given name
+func (l *Lobby) Room(name string) (*ChatRoom, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	room, ok := l.rooms[name]
+	return room, ok
+}
+
+// Rooms returns the names of all rooms in alphabetical order
+func (l *Lobby) Rooms() []string {
+	l.mu.RLock()
+	names := make([]string, 0, len(l.rooms))
+	for name := range l.rooms {
+		names = append(names, name)
+	}
+	l.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// roomList returns a snapshot of the rooms, so they are called without holding l.mu
+func (l *Lobby) roomList() []*ChatRoom {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	rooms := make([]*ChatRoom, 0, len(l.rooms))
+	for _, room := range l.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Join adds a registered user to a room
+func (l *Lobby) Join(roomName string, user Obj) error {
+	if !l.hall.IsMember(user.GetID()) {
+		return fmt.Errorf("lobby: user %s is not registered", user.GetName())
+	}
+	room, ok := l.Room(roomName)
+	if !ok {
+		return fmt.Errorf("lobby: room %s does not exist", roomName)
+	}
+	if room.IsBanned(user.GetID()) {
+		return fmt.Errorf("lobby: user %s is banned from room %s", user.GetName(), roomName)
+	}
+	room.Join(user)
+	return nil
+}
+
+// Leave removes a user from a room
+func (l *Lobby) Leave(roomName string, user Obj) error {
+	room, ok := l.Room(roomName)
+	if !ok {
+		return fmt.Errorf("lobby: room %s does not exist", roomName)
+	}
+	room.Leave(user)
+	return nil
+}
+
+// SendMsg broadcasts an announcement to every registered user except the sender
+// Announcements from unregistered users or rejected by moderation are dropped.
+func (l *Lobby) SendMsg(msg string, user Obj) {
+	if !l.hall.IsMember(user.GetID()) {
+		return
+	}
+	msg, err := l.hall.moderate(user, msg, false)
+	if err != nil {
+		return
+	}
+	l.hall.broadcast(msg, user.GetID())
+}
+
+// SendPrivate delivers a message to any registered user, whichever rooms they are in
+func (l *Lobby) SendPrivate(msg string, from Obj, toID string) error {
+	l.hall.mu.RLock()
+	_, registered := l.hall.member(from.GetID())
+	to, ok := l.hall.member(toID)
+	l.hall.mu.RUnlock()
+	if !registered {
+		return fmt.Errorf("lobby: user %s is not registered", from.GetName())
+	}
+	if !ok {
+		return fmt.Errorf("lobby: user %s is not online", toID)
+	}
+	msg, err := l.hall.moderate(from, msg, true)
+	if err != nil {
+		return err
+	}
+	l.hall.deliver(to, envelope{msg: fmt.Sprintf("[私聊] %s: %s", from.GetName(), msg)}) // [Private]
+	return nil
+}
+
+// Dropped returns how many lobby messages were dropped for the user with the given ID
+func (l *Lobby) Dropped(id string) uint64 {
+	return l.hall.Dropped(id)
+}
+
+// Flush waits until every message queued so far, in the lobby and its rooms, has been delivered
+func (l *Lobby) Flush() {
+	l.hall.Flush()
+	for _, room := range l.roomList() {
+		room.Flush()
+	}
+}
+
+// Close closes every room and the lobby, waiting for pending messages to be delivered
+func (l *Lobby) Close() {
+	for _, room := range l.roomList() {
+		room.Close()
+	}
+	l.hall.Close()
+}
+
+// Heartbeat keeps the user online in every room they are in
+func (l *Lobby) Heartbeat(user Obj) {
+	for _, room := range l.roomList() {
+		room.Heartbeat(user)
+	}
+}
+
+// Typing shows the typing indicator in every room the user is in
+func (l *Lobby) Typing(user Obj, typing bool) {
+	for _, room := range l.roomList() {
+		room.Typing(user, typing)
+	}
+}
+
+// MarkRead records a read receipt in the room the message was sent to
+func (l *Lobby) MarkRead(user Obj, msgID string) error {
+	for _, room := range l.roomList() {
+		err := room.MarkRead(user, msgID)
+		if !errors.Is(err, ErrUnknownMessage) {
+			return err
+		}
+	}
+	return fmt.Errorf("lobby: %w %s", ErrUnknownMessage, msgID)
+}
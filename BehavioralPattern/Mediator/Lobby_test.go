@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a user that records the messages it receives
+type recorder struct {
+	User
+	mu    sync.Mutex
+	msgs  []string
+	block chan struct{} // When set, RevMsg waits on it before recording
+}
+
+func newRecorder(name string, mediator Mediator) *recorder {
+	return &recorder{User: NewUser(name, mediator)}
+}
+
+func (r *recorder) RevMsg(msg string) {
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	r.msgs = append(r.msgs, msg)
+	r.mu.Unlock()
+}
+
+func (r *recorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.msgs...)
+}
+
+func TestLobbyConcurrentUse(t *testing.T) {
+	lobby := NewLobby()
+	target := newRecorder("目标", lobby)
+	lobby.Register(target)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := newRecorder(fmt.Sprintf("用户%d", i), lobby)
+			if err := lobby.Register(user); err != nil {
+				t.Error(err)
+				return
+			}
+			if _, err := lobby.CreateRoom(fmt.Sprintf("房间%d", i), user); err != nil {
+				t.Error(err)
+			}
+			lobby.Rooms()
+			if err := lobby.SendPrivate("你好", user, target.GetID()); err != nil {
+				t.Error(err)
+			}
+			lobby.Heartbeat(user)
+		}(i)
+	}
+	wg.Wait()
+	lobby.Flush()
+	if got := len(target.received()); got != 8 {
+		t.Fatalf("target received %d messages, want 8", got)
+	}
+	if got := len(lobby.Rooms()); got != 8 {
+		t.Fatalf("lobby has %d rooms, want 8", got)
+	}
+	lobby.Close()
+}
+
+func TestLobbyDeliversThroughInboxes(t *testing.T) {
+	lobby := NewLobby()
+	sender := newRecorder("发送者", lobby)
+	slow := newRecorder("慢", lobby)
+	slow.block = make(chan struct{})
+	lobby.Register(sender)
+	lobby.Register(slow)
+
+	// A recipient that never reads must not block the sender
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < DefaultInboxSize+10; i++ {
+			lobby.SendPrivate(fmt.Sprintf("消息%d", i), sender, slow.GetID())
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SendPrivate blocked on a slow recipient")
+	}
+	if got := lobby.Dropped(slow.GetID()); got == 0 {
+		t.Fatal("expected messages to be dropped for the slow recipient")
+	}
+	close(slow.block)
+	lobby.Close()
+}
+
+func TestLobbyRejectsUsersWithoutID(t *testing.T) {
+	lobby := NewLobby()
+	if err := lobby.Register(User{Name: "无名"}); !errors.Is(err, ErrNoUserID) {
+		t.Fatalf("Register returned %v, want ErrNoUserID", err)
+	}
+	if _, err := lobby.CreateRoom("Go", User{Name: "无名"}); !errors.Is(err, ErrNoUserID) {
+		t.Fatalf("CreateRoom returned %v, want ErrNoUserID", err)
+	}
+}
+
+func TestLobbyModeratesPrivateMessages(t *testing.T) {
+	lobby := NewLobby(BannedWords{Words: []string{"坏蛋"}}, LinkBlocker{})
+	sender := newRecorder("发送者", lobby)
+	target := newRecorder("目标", lobby)
+	lobby.Register(sender)
+	lobby.Register(target)
+
+	if err := lobby.SendPrivate("你是坏蛋", sender, target.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	var rejected *RejectedError
+	if err := lobby.SendPrivate("看 http://evil.example.com", sender, target.GetID()); !errors.As(err, &rejected) {
+		t.Fatalf("link got %v, want a RejectedError", err)
+	}
+	lobby.SendMsg("大家看 www.evil.example.com", sender)
+	lobby.Flush()
+
+	if got := target.received(); len(got) != 1 || got[0] != "[私聊] 发送者: 你是**" {
+		t.Fatalf("target received %q, want only the masked message", got)
+	}
+	notes := 0
+	for _, msg := range sender.received() {
+		if strings.HasPrefix(msg, "[系统] 消息未发送") {
+			notes++
+		}
+	}
+	if notes != 2 {
+		t.Fatalf("sender got %d rejection notes, want 2: %q", notes, sender.received())
+	}
+	lobby.Close()
+}
+
+func TestRoomSanctionsStayInTheRoom(t *testing.T) {
+	lobby := NewLobby()
+	griefer := newRecorder("捣乱", lobby)
+	victim := newRecorder("受害者", lobby)
+	friend := newRecorder("朋友", lobby)
+	for _, user := range []Obj{griefer, victim, friend} {
+		lobby.Register(user)
+	}
+	defer lobby.Close()
+
+	// Owning a room gives no power over users who never joined it
+	mine, _ := lobby.CreateRoom("mine", griefer)
+	if err := mine.Ban(griefer, victim.GetID()); err == nil {
+		t.Fatal("banned a user who never joined the room")
+	}
+	if err := mine.Mute(griefer, victim.GetID(), 0); err == nil {
+		t.Fatal("muted a user who never joined the room")
+	}
+	if err := lobby.Join("mine", victim); err != nil {
+		t.Fatal(err)
+	}
+
+	// A member can be muted and banned, but only inside the room
+	if err := mine.Mute(griefer, victim.GetID(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mine.Post(victim, "你好"); err == nil {
+		t.Fatal("muted member posted in the room")
+	}
+	if err := mine.Ban(griefer, victim.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := lobby.Join("mine", victim); err == nil {
+		t.Fatal("banned user joined the room again")
+	}
+	if err := lobby.SendPrivate("hi", victim, friend.GetID()); err != nil {
+		t.Fatalf("room sanctions blocked a lobby DM: %v", err)
+	}
+	lobby.Flush()
+	if got := friend.received(); len(got) != 1 || got[0] != "[私聊] 受害者: hi" {
+		t.Fatalf("friend received %q", got)
+	}
+}
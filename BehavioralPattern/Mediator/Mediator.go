@@ -21,65 +21,482 @@ Benefits:
 
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // Obj defines the interface for objects that can communicate through the mediator
 type Obj interface {
-	SendMsg(string) // Send a message
-	RevMsg(string)  // Receive a message
+	GetID() string               // Unique identifier of the user
+	GetName() string             // Display name, not necessarily unique
+	SendMsg(string)              // Send a message
+	RevMsg(string)               // Receive a message
+	Heartbeat()                  // Report that the user is still active
+	SetTyping(bool)              // Report that the user started or stopped typing
+	MarkRead(msgID string) error // Report that the user has read a message
 }
 
 // Mediator defines the interface for the communication mediator
 type Mediator interface {
-	SendMsg(msg string, user Obj) // Distribute message to other users
+	SendMsg(msg string, user Obj)                        // Distribute message to other users
+	SendPrivate(msg string, from Obj, toID string) error // Deliver a message to a single user
+	Heartbeat(user Obj)                                  // Keep the user's presence online
+	Typing(user Obj, typing bool)                        // Tell other users the user is typing
+	MarkRead(user Obj, msgID string) error               // Record a read receipt
 }
 
+// MessageReceiver is implemented by users that want chat messages with their IDs,
+// for example to send read receipts; they get RevMessage instead of RevMsg.
+type MessageReceiver interface {
+	RevMessage(msg Message)
+}
+
+// lastUserID is the last identifier handed out by NewUserID
+var lastUserID atomic.Uint64
+
+// NewUserID returns a process-wide unique user identifier
+func NewUserID() string {
+	return fmt.Sprintf("u%d", lastUserID.Add(1))
+}
+
+// ErrNoUserID is returned when registering a user without an identifier,
+// such as a User built as a struct literal instead of with NewUser
+var ErrNoUserID = errors.New("mediator: user has no ID")
+
+// lastMessageID is the last chat message identifier handed out by a ChatRoom
+var lastMessageID atomic.Uint64
+
 // User represents a chat participant
 type User struct {
 	Name     string   // User's name
+	id       string   // Unique identifier
 	mediator Mediator // Reference to the mediator
 }
 
+// NewUser creates a user with a unique identifier
+func NewUser(name string, mediator Mediator) User {
+	return User{
+		Name:     name,
+		id:       NewUserID(),
+		mediator: mediator,
+	}
+}
+
+// GetID returns the user's unique identifier
+func (u User) GetID() string {
+	return u.id
+}
+
+// GetName returns the user's name
+func (u User) GetName() string {
+	return u.Name
+}
+
 // SendMsg sends a message through the mediator
 func (u User) SendMsg(msg string) {
 	fmt.Printf("用户 %s 发了消息 %s\n", u.Name, msg) // User [name] sent message [msg]
 	u.mediator.SendMsg(msg, u)
 }
 
+// SendPrivate sends a message to a single user through the mediator
+func (u User) SendPrivate(toID, msg string) error {
+	fmt.Printf("用户 %s 私聊 %s %s\n", u.Name, toID, msg) // User [name] privately messaged [id] [msg]
+	return u.mediator.SendPrivate(msg, u, toID)
+}
+
 // RevMsg receives a message from the mediator
 func (u User) RevMsg(msg string) {
 	fmt.Printf("用户 %s 接收到消息 %s\n", u.Name, msg) // User [name] received message [msg]
 }
 
+// Heartbeat tells the mediator the user is still active
+func (u User) Heartbeat() {
+	u.mediator.Heartbeat(u)
+}
+
+// SetTyping tells the mediator the user started or stopped typing
+func (u User) SetTyping(typing bool) {
+	u.mediator.Typing(u, typing)
+}
+
+// MarkRead tells the mediator the user has read a message
+func (u User) MarkRead(msgID string) error {
+	return u.mediator.MarkRead(u, msgID)
+}
+
+// SlowUser is a user that takes a while to process each message
+type SlowUser struct {
+	User
+	Delay time.Duration
+}
+
+// RevMsg receives a message after the delay
+func (s *SlowUser) RevMsg(msg string) {
+	time.Sleep(s.Delay)
+	s.User.RevMsg(msg)
+}
+
 // ChatRoom implements the mediator for user communication
+// It is safe for concurrent use: every member has a bounded inbox drained by
+// its own goroutine, so senders never wait on a slow member's RevMsg.
 type ChatRoom struct {
-	users []User // List of users in the chat room
+	Name         string             // Room name
+	InboxSize    int                // Capacity of each member's inbox, DefaultInboxSize when zero
+	Policy       SlowConsumerPolicy // What to do when a member's inbox is full
+	BlockTimeout time.Duration      // How long BlockWithTimeout waits, DefaultBlockTimeout when zero
+	History      *History           // Records chat messages when set
+	Replay       int                // Number of recent messages sent to users when they join
+	Filters      []Filter           // Moderation filters run in order before delivery
+	OnFlag       func(Post)         // Called for delivered messages that a filter flagged
+	AwayAfter    time.Duration      // Silence after which a member is away, DefaultAwayAfter when zero
+	OfflineAfter time.Duration      // Silence after which a member is offline, DefaultOfflineAfter when zero
+	Now          func() time.Time   // Clock, time.Now when nil
+
+	mu      sync.RWMutex
+	members []*inbox             // Users in the chat room, in join order
+	admins  map[string]bool      // IDs of users allowed to manage the room
+	muted   map[string]time.Time // Muted user IDs, until the given time or forever when zero
+	banned  map[string]bool      // IDs of users who may not join
+	joined  map[string]bool      // IDs of current and former members, who may be muted or banned
+	reads   map[string]*readLog  // Read receipts by message ID
+	order   []string             // Message IDs in reads, oldest first
+
+	statsMu sync.Mutex
+	dropped map[string]uint64 // Dropped messages by user ID, kept after the user leaves
+}
+
+// NewChatRoom creates an empty room
+func NewChatRoom(name string) *ChatRoom {
+	return &ChatRoom{Name: name}
 }
 
 // Register adds a new user to the chat room
-func (c *ChatRoom) Register(user User) {
-	c.users = append(c.users, user)
+// Members are keyed by ID, so users without one are rejected with ErrNoUserID.
+func (c *ChatRoom) Register(user Obj) error {
+	if user.GetID() == "" {
+		return ErrNoUserID
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(user)
+	return nil
+}
+
+// add creates an inbox for user unless it has no ID, is already a member or is banned
+// The caller must hold c.mu
+func (c *ChatRoom) add(user Obj) bool {
+	if user.GetID() == "" {
+		return false
+	}
+	if _, ok := c.member(user.GetID()); ok || c.banned[user.GetID()] {
+		return false
+	}
+	size := c.InboxSize
+	if size <= 0 {
+		size = DefaultInboxSize
+	}
+	in := newInbox(user, size)
+	in.lastSeen = c.now()
+	in.presence = Online
+	c.members = append(c.members, in)
+	if c.joined == nil {
+		c.joined = map[string]bool{}
+	}
+	c.joined[user.GetID()] = true
+	// Queued while holding c.mu, so the replay arrives before any new message
+	if c.History != nil && c.Replay > 0 {
+		for _, msg := range c.History.Last(c.Replay) {
+			in.offer(envelope{msg: fmt.Sprintf("[历史 %s] %s: %s", msg.Time.Format("15:04"), msg.Name, msg.Text)}, DropOldest, 0) // [History]
+		}
+	}
+	return true
+}
+
+// Unregister removes a user from the chat room
+// Messages already in the user's inbox are still delivered.
+func (c *ChatRoom) Unregister(user Obj) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(user.GetID())
+}
+
+// remove drops a member by ID, closes its inbox and returns it
+// The caller must hold c.mu
+func (c *ChatRoom) remove(id string) (*inbox, bool) {
+	for i, in := range c.members {
+		if in.user.GetID() == id {
+			c.members = append(c.members[:i], c.members[i+1:]...)
+			in.close()
+			return in, true
+		}
+	}
+	return nil, false
+}
+
+// member returns the inbox of the member with the given ID
+// The caller must hold c.mu
+func (c *ChatRoom) member(id string) (*inbox, bool) {
+	for _, in := range c.members {
+		if in.user.GetID() == id {
+			return in, true
+		}
+	}
+	return nil, false
+}
+
+// IsMember reports whether the user with the given ID is in the room
+func (c *ChatRoom) IsMember(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.member(id)
+	return ok
+}
+
+// Members returns the users currently in the room
+func (c *ChatRoom) Members() []Obj {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	users := make([]Obj, len(c.members))
+	for i, in := range c.members {
+		users[i] = in.user
+	}
+	return users
+}
+
+// Join adds a user and announces it to the members
+// Banned users and users without an ID are not added.
+func (c *ChatRoom) Join(user Obj) {
+	c.mu.Lock()
+	added := c.add(user)
+	c.mu.Unlock()
+	if added {
+		c.broadcast(fmt.Sprintf("[%s] %s 加入了房间", c.Name, user.GetName()), user.GetID()) // [name] joined the room
+	}
+}
+
+// Leave removes a user and announces it to the remaining members
+func (c *ChatRoom) Leave(user Obj) {
+	c.mu.Lock()
+	_, removed := c.remove(user.GetID())
+	c.mu.Unlock()
+	if removed {
+		c.broadcast(fmt.Sprintf("[%s] %s 离开了房间", c.Name, user.GetName()), "") // [name] left the room
+	}
+}
+
+// AddAdmin allows the user with the given ID to manage the room
+func (c *ChatRoom) AddAdmin(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.admins == nil {
+		c.admins = map[string]bool{}
+	}
+	c.admins[id] = true
+}
+
+// IsAdmin reports whether the user with the given ID manages the room
+func (c *ChatRoom) IsAdmin(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.admins[id]
+}
+
+// Kick removes a member on behalf of an admin; everyone, including the kicked user, is told
+func (c *ChatRoom) Kick(admin Obj, targetID string) error {
+	if !c.IsAdmin(admin.GetID()) {
+		return fmt.Errorf("room %s: %s is not an admin", c.Name, admin.GetName())
+	}
+	c.mu.RLock()
+	target, ok := c.member(targetID)
+	c.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("room %s: user %s is not a member", c.Name, targetID)
+	}
+	event := fmt.Sprintf("[%s] %s 被 %s 踢出了房间", c.Name, target.user.GetName(), admin.GetName()) // [name] was kicked by [admin]
+	c.broadcast(event, "")
+	c.mu.Lock()
+	c.remove(targetID)
+	c.mu.Unlock()
+	return nil
+}
+
+// broadcast queues a message for every member except the one with skipID
+func (c *ChatRoom) broadcast(msg, skipID string) {
+	c.fanOut(envelope{msg: msg}, skipID)
+}
+
+// fanOut queues env for every member except the one with skipID
+func (c *ChatRoom) fanOut(env envelope, skipID string) {
+	c.mu.RLock()
+	members := append([]*inbox(nil), c.members...)
+	c.mu.RUnlock()
+	for _, in := range members {
+		if in.user.GetID() == skipID {
+			continue
+		}
+		c.deliver(in, env)
+	}
+}
+
+// deliver queues env in one inbox and applies the slow-consumer policy
+func (c *ChatRoom) deliver(in *inbox, env envelope) {
+	timeout := c.BlockTimeout
+	if timeout <= 0 {
+		timeout = DefaultBlockTimeout
+	}
+	dropped, disconnect := in.offer(env, c.Policy, timeout)
+	if dropped {
+		c.statsMu.Lock()
+		if c.dropped == nil {
+			c.dropped = map[string]uint64{}
+		}
+		c.dropped[in.user.GetID()]++
+		c.statsMu.Unlock()
+	}
+	if disconnect {
+		c.disconnect(in.user)
+	}
+}
+
+// disconnect removes a member that cannot keep up
+// Users that implement io.Closer, such as RemoteUser, are also closed.
+func (c *ChatRoom) disconnect(user Obj) {
+	c.mu.Lock()
+	_, removed := c.remove(user.GetID())
+	c.mu.Unlock()
+	if !removed {
+		return
+	}
+	if closer, ok := user.(io.Closer); ok {
+		// Closing may wait on the connection the member is stuck writing to
+		go closer.Close()
+	}
+	c.broadcast(fmt.Sprintf("[%s] %s 因接收过慢被断开", c.Name, user.GetName()), "") // [name] was disconnected for being too slow
+}
+
+// Dropped returns how many messages were dropped for the user with the given ID
+func (c *ChatRoom) Dropped(id string) uint64 {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.dropped[id]
+}
+
+// TotalDropped returns how many messages were dropped for all users
+func (c *ChatRoom) TotalDropped() uint64 {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	var total uint64
+	for _, n := range c.dropped {
+		total += n
+	}
+	return total
+}
+
+// Flush waits until every message queued so far has been delivered
+func (c *ChatRoom) Flush() {
+	c.mu.RLock()
+	members := append([]*inbox(nil), c.members...)
+	c.mu.RUnlock()
+	for _, in := range members {
+		in.flush()
+	}
+}
+
+// Close removes every member and waits for their pending messages to be delivered
+func (c *ChatRoom) Close() {
+	c.mu.Lock()
+	members := c.members
+	c.members = nil
+	for _, in := range members {
+		in.close()
+	}
+	c.mu.Unlock()
+	for _, in := range members {
+		<-in.done
+	}
 }
 
 // SendMsg distributes a message to all users except the sender
+// Messages from users who are not members are dropped
 func (c *ChatRoom) SendMsg(msg string, user Obj) {
-	for _, u := range c.users {
-		if u == user {
-			continue // Skip the sender
-		}
-		u.RevMsg(msg)
+	c.Post(user, msg)
+}
+
+// Post distributes a message like SendMsg and returns its ID for read receipts
+func (c *ChatRoom) Post(user Obj, text string) (string, error) {
+	if !c.IsMember(user.GetID()) {
+		return "", fmt.Errorf("room %s: %s is not a member", c.Name, user.GetName())
+	}
+	text, err := c.moderate(user, text, false)
+	if err != nil {
+		return "", err
 	}
+	msg := Message{
+		ID:   fmt.Sprintf("m%d", lastMessageID.Add(1)),
+		Time: c.now(),
+		From: user.GetID(),
+		Name: user.GetName(),
+		Text: text,
+	}
+	c.sent(user, msg)
+	if c.History != nil {
+		c.History.Append(msg)
+	}
+	c.fanOut(envelope{msg: text, message: &msg}, user.GetID()) // Skip the sender
+	return msg.ID, nil
+}
+
+// SendPrivate delivers a message from one member to another
+func (c *ChatRoom) SendPrivate(msg string, from Obj, toID string) error {
+	c.mu.RLock()
+	_, isMember := c.member(from.GetID())
+	to, ok := c.member(toID)
+	c.mu.RUnlock()
+	if !isMember {
+		return fmt.Errorf("room %s: %s is not a member", c.Name, from.GetName())
+	}
+	if !ok {
+		return fmt.Errorf("room %s: user %s is not a member", c.Name, toID)
+	}
+	msg, err := c.moderate(from, msg, true)
+	if err != nil {
+		return err
+	}
+	c.deliver(to, envelope{msg: fmt.Sprintf("[私聊] %s: %s", from.GetName(), msg)}) // [Private]
+	return nil
 }
 
 // Example usage of the Mediator Pattern
+// Run with -tcp and/or -http to serve the chat room over the network instead
 func main() {
+	tcpAddr := flag.String("tcp", "", "address for line-protocol TCP clients, e.g. :9000")
+	httpAddr := flag.String("http", "", "address for WebSocket clients, e.g. :8080")
+	flag.Parse()
+	if *tcpAddr != "" || *httpAddr != "" {
+		if err := serve(*tcpAddr, *httpAddr); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
 	// Create the mediator (chat room)
 	room := ChatRoom{}
 
 	// Create users with references to the mediator
-	u1 := User{Name: "枫枫", mediator: &room} // User Feng Feng
-	u2 := User{Name: "张三", mediator: &room} // User Zhang San
-	u3 := User{Name: "李四", mediator: &room} // User Li Si
+	u1 := NewUser("枫枫", &room) // User Feng Feng
+	u2 := NewUser("张三", &room) // User Zhang San
+	u3 := NewUser("李四", &room) // User Li Si
 
 	// Register users in the chat room
 	room.Register(u1)
@@ -87,7 +504,178 @@ func main() {
 	room.Register(u3)
 
 	// Demonstrate communication through the mediator
+	// Delivery is asynchronous, Flush waits for it to keep the output in order
 	u1.SendMsg("你好啊") // Hello
+	room.Flush()
 	u2.SendMsg("吃了吗") // Have you eaten?
+	room.Flush()
 	u3.SendMsg("我吃了") // I have eaten
+	room.Close()
+
+	// A slow member only loses its own oldest messages, the sender never waits
+	busy := NewChatRoom("忙碌") // Busy
+	busy.InboxSize = 2
+	busy.Policy = DropOldest
+	fast := NewUser("快", busy)                                                // Fast
+	slow := &SlowUser{User: NewUser("慢", busy), Delay: 10 * time.Millisecond} // Slow
+	busy.Register(fast)
+	busy.Register(slow)
+	for i := 1; i <= 5; i++ {
+		busy.SendMsg(fmt.Sprintf("消息%d", i), fast) // Message i
+	}
+	busy.Close()
+	fmt.Printf("用户 %s 丢失了 %d 条消息\n", slow.Name, busy.Dropped(slow.GetID())) // User [name] lost [n] messages
+
+	// Late joiners see the last messages, and the history can be searched
+	historyDir, _ := os.MkdirTemp("", "chat")
+	defer os.RemoveAll(historyDir)
+	history, err := OpenHistory(filepath.Join(historyDir, "history.jsonl"), 100)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer history.Close()
+	tea := NewChatRoom("茶馆") // Teahouse
+	tea.History = history
+	tea.Replay = 2
+	host := NewUser("老板", tea) // Owner
+	tea.Register(host)
+	host.SendMsg("开门了")         // We're open
+	host.SendMsg("今天有龙井")       // Longjing tea today
+	host.SendMsg("龙井五折")        // Longjing half price
+	guest := NewUser("客人", tea) // Guest
+	tea.Register(guest)
+	tea.Close()
+	found, _ := history.Search(Query{Keyword: "龙井", From: "老板"})
+	for _, msg := range found {
+		fmt.Printf("搜索结果 %s: %s\n", msg.Name, msg.Text) // Search result
+	}
+
+	// Messages pass through moderation filters before delivery
+	club := NewChatRoom("俱乐部") // Club
+	club.Filters = []Filter{
+		BannedWords{Words: []string{"笨蛋"}}, // Idiot
+		LinkBlocker{Allowed: []string{"go.dev"}},
+		NewRateLimit(1, 2),
+	}
+	club.OnFlag = func(p Post) {
+		fmt.Printf("标记 %s 的消息: %v\n", p.From.GetName(), p.Flags) // Flagged message from [name]
+	}
+	admin := NewUser("管理员", club) // Admin
+	member := NewUser("会员", club) // Member
+	club.AddAdmin(admin.GetID())
+	club.Register(admin)
+	club.Register(member)
+	member.SendMsg("你是笨蛋")                      // You are an idiot
+	member.SendMsg("看 https://go.dev/doc")      // See https://go.dev/doc
+	member.SendMsg("看 http://spam.example.com") // See http://spam.example.com
+	club.Flush()
+	club.Mute(admin, member.GetID(), time.Minute)
+	if err := member.SendPrivate(admin.GetID(), "为什么禁言我"); err != nil { // Why mute me
+		fmt.Println(err)
+	}
+	club.Ban(admin, member.GetID())
+	club.Close()
+
+	// Presence, typing indicators and read receipts
+	clock := time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local)
+	office := NewChatRoom("办公室") // Office
+	office.Now = func() time.Time { return clock }
+	boss := NewUser("老王", office)  // Lao Wang
+	staff := NewUser("小李", office) // Xiao Li
+	office.Register(boss)
+	office.Register(staff)
+	staff.SetTyping(true)
+	office.Flush()
+	msgID, _ := office.Post(staff, "报告写完了") // The report is done
+	office.Flush()
+	clock = clock.Add(2 * time.Minute)
+	boss.Heartbeat()
+	office.CheckPresence()
+	office.Flush()
+	fmt.Println(staff.Name, office.Presence(staff.GetID()), boss.Name, office.Presence(boss.GetID()))
+	boss.MarkRead(msgID)
+	receipts, _ := office.Receipts(staff, msgID)
+	for _, receipt := range receipts {
+		fmt.Printf("%s 已读 %s\n", receipt.Name, receipt.At.Format("15:04")) // Read at
+	}
+	office.Close()
+
+	// Services talk through a typed bus instead of calling each other
+	bus := NewBus()
+	bus.Use(
+		LoggingBehavior(func(format string, args ...any) { fmt.Printf(format+"\n", args...) }),
+		ValidationBehavior(),
+		TimingBehavior(func(msg any, d time.Duration) {
+			fmt.Printf("%T 耗时 %v\n", msg, d.Round(time.Millisecond)) // Took
+		}),
+	)
+	Handle(bus, func(ctx context.Context, req PlaceOrder) (string, error) {
+		orderID := fmt.Sprintf("order-%d", req.Quantity)
+		return orderID, Publish(ctx, bus, OrderPlaced{OrderID: orderID, Item: req.Item})
+	})
+	Subscribe(bus, func(ctx context.Context, n OrderPlaced) error {
+		fmt.Printf("仓库备货 %s %s\n", n.OrderID, n.Item) // Warehouse prepares the goods
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, n OrderPlaced) error {
+		fmt.Printf("通知买家 %s\n", n.OrderID) // Notify the buyer
+		return nil
+	})
+	orderID, err := Send[PlaceOrder, string](context.Background(), bus, PlaceOrder{Item: "键盘", Quantity: 2}) // Keyboard
+	fmt.Println(orderID, err)
+	_, err = Send[PlaceOrder, string](context.Background(), bus, PlaceOrder{Item: "键盘"})
+	fmt.Println(err)
+
+	// Users with the same name stay distinct thanks to their IDs
+	lobby := NewLobby()
+	alice := NewUser("张三", lobby)
+	bob := NewUser("张三", lobby)
+	carol := NewUser("王五", lobby) // User Wang Wu
+	lobby.Register(alice)
+	lobby.Register(bob)
+	lobby.Register(carol)
+
+	// The first 张三 creates a room, the others join, and the second 张三 is kicked out
+	golang, _ := lobby.CreateRoom("Go", alice)
+	lobby.Join("Go", bob)
+	lobby.Join("Go", carol)
+	golang.SendMsg("欢迎", alice) // Welcome
+	golang.Flush()
+	if err := golang.Kick(alice, bob.GetID()); err != nil {
+		fmt.Println(err)
+	}
+
+	// Private messages are routed through the lobby
+	golang.Flush()
+	if err := carol.SendPrivate(bob.GetID(), "别难过"); err != nil { // Don't be sad
+		fmt.Println(err)
+	}
+	lobby.Close()
+}
+
+// serve runs the networked chat room until one of the listeners fails
+func serve(tcpAddr, httpAddr string) error {
+	room := NewChatRoom("大厅") // Hall
+	room.Policy = Disconnect  // Drop clients that stop reading instead of buffering for them
+	server := NewServer(room)
+	go func() {
+		for range time.Tick(10 * time.Second) {
+			room.CheckPresence()
+		}
+	}()
+	errs := make(chan error, 2)
+	if tcpAddr != "" {
+		listener, err := net.Listen("tcp", tcpAddr)
+		if err != nil {
+			return err
+		}
+		fmt.Println("TCP 监听", listener.Addr()) // TCP listening
+		go func() { errs <- server.ServeTCP(listener) }()
+	}
+	if httpAddr != "" {
+		fmt.Println("WebSocket 监听", httpAddr) // WebSocket listening
+		go func() { errs <- http.ListenAndServe(httpAddr, server) }()
+	}
+	return <-errs
 }
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Post is a message passing through the room's moderation filters
+type Post struct {
+	From    Obj
+	Text    string   // Filters may rewrite the text
+	Private bool     // Whether the message is a private message
+	Flags   []string // Reasons the message was flagged for review
+}
+
+// Flag marks the post for review without stopping it
+func (p *Post) Flag(reason string) {
+	p.Flags = append(p.Flags, reason)
+}
+
+// Filter inspects a post before it is delivered
+// A filter may modify p.Text, call p.Flag, or return an error to reject the post.
+type Filter interface {
+	Apply(room *ChatRoom, p *Post) error
+}
+
+// FilterFunc adapts a function to the Filter interface
+type FilterFunc func(room *ChatRoom, p *Post) error
+
+// Apply calls f
+func (f FilterFunc) Apply(room *ChatRoom, p *Post) error {
+	return f(room, p)
+}
+
+// RejectedError reports why a message was not delivered
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "message rejected: " + e.Reason
+}
+
+// Reject returns a RejectedError with the given reason
+func Reject(reason string) error {
+	return &RejectedError{Reason: reason}
+}
+
+// BannedWords masks banned words, ignoring case
+type BannedWords struct {
+	Words []string
+	Mask  rune // Replacement character, '*' when zero
+}
+
+// Apply masks every occurrence of a banned word
+func (b BannedWords) Apply(room *ChatRoom, p *Post) error {
+	mask := b.Mask
+	if mask == 0 {
+		mask = '*'
+	}
+	for _, word := range b.Words {
+		if word == "" {
+			continue
+		}
+		pattern := regexp.MustCompile("(?i)" + regexp.QuoteMeta(word))
+		if pattern.MatchString(p.Text) {
+			p.Text = pattern.ReplaceAllStringFunc(p.Text, func(match string) string {
+				return strings.Repeat(string(mask), len([]rune(match)))
+			})
+			p.Flag("banned word: " + word)
+		}
+	}
+	return nil
+}
+
+// linkPattern matches http(s) URLs and bare www. hosts
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s]+`)
+
+// LinkBlocker rejects messages containing links to hosts that are not allowed
+type LinkBlocker struct {
+	Allowed  []string // Hosts, and their subdomains, that may be linked
+	FlagOnly bool     // Flag instead of rejecting
+}
+
+// Apply checks every link in the message
+func (l LinkBlocker) Apply(room *ChatRoom, p *Post) error {
+	for _, link := range linkPattern.FindAllString(p.Text, -1) {
+		if l.allowed(link) {
+			continue
+		}
+		if l.FlagOnly {
+			p.Flag("link: " + link)
+			continue
+		}
+		return Reject("不允许发送链接 " + link) // Links are not allowed
+	}
+	return nil
+}
+
+// allowed reports whether link points to an allowed host
+func (l LinkBlocker) allowed(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range l.Allowed {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimit allows each user Rate messages per second with bursts of Burst messages
+type RateLimit struct {
+	Rate  float64
+	Burst int
+	Now   func() time.Time // Clock, time.Now when nil
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// bucket is the token bucket of one user
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimit creates a per-user rate limit
+func NewRateLimit(rate float64, burst int) *RateLimit {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimit{Rate: rate, Burst: burst}
+}
+
+// Apply takes a token from the sender's bucket or rejects the post
+func (r *RateLimit) Apply(room *ChatRoom, p *Post) error {
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.buckets == nil {
+		r.buckets = map[string]*bucket{}
+	}
+	id := p.From.GetID()
+	b, ok := r.buckets[id]
+	if !ok {
+		b = &bucket{tokens: float64(r.Burst), last: now()}
+		r.buckets[id] = b
+	}
+	t := now()
+	b.tokens += t.Sub(b.last).Seconds() * r.Rate
+	if b.tokens > float64(r.Burst) {
+		b.tokens = float64(r.Burst)
+	}
+	b.last = t
+	if b.tokens < 1 {
+		return Reject("发言太快，请稍后再试") // Too fast, try again later
+	}
+	b.tokens--
+	return nil
+}
+
+// Mute stops a member from sending messages for d, or until unmuted when d <= 0
+// Only current and former members of the room can be muted.
+func (c *ChatRoom) Mute(admin Obj, targetID string, d time.Duration) error {
+	if !c.IsAdmin(admin.GetID()) {
+		return fmt.Errorf("room %s: %s is not an admin", c.Name, admin.GetName())
+	}
+	until := time.Time{}
+	if d > 0 {
+		until = c.now().Add(d)
+	}
+	c.mu.Lock()
+	if !c.joined[targetID] {
+		c.mu.Unlock()
+		return fmt.Errorf("room %s: user %s has never been a member", c.Name, targetID)
+	}
+	if c.muted == nil {
+		c.muted = map[string]time.Time{}
+	}
+	c.muted[targetID] = until
+	c.mu.Unlock()
+	return nil
+}
+
+// Unmute lets a member send messages again
+func (c *ChatRoom) Unmute(admin Obj, targetID string) error {
+	if !c.IsAdmin(admin.GetID()) {
+		return fmt.Errorf("room %s: %s is not an admin", c.Name, admin.GetName())
+	}
+	c.mu.Lock()
+	delete(c.muted, targetID)
+	c.mu.Unlock()
+	return nil
+}
+
+// IsMuted reports whether the user with the given ID may not send messages
+func (c *ChatRoom) IsMuted(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	until, ok := c.muted[id]
+	return ok && (until.IsZero() || c.now().Before(until))
+}
+
+// Ban removes a member and keeps them from joining again
+// Only current and former members of the room can be banned.
+func (c *ChatRoom) Ban(admin Obj, targetID string) error {
+	if !c.IsAdmin(admin.GetID()) {
+		return fmt.Errorf("room %s: %s is not an admin", c.Name, admin.GetName())
+	}
+	c.mu.Lock()
+	if !c.joined[targetID] {
+		c.mu.Unlock()
+		return fmt.Errorf("room %s: user %s has never been a member", c.Name, targetID)
+	}
+	if c.banned == nil {
+		c.banned = map[string]bool{}
+	}
+	c.banned[targetID] = true
+	target, ok := c.member(targetID)
+	c.mu.Unlock()
+	if ok {
+		// Everyone, including the banned user, is told before the inbox closes
+		c.broadcast(fmt.Sprintf("[%s] %s 被 %s 封禁", c.Name, target.user.GetName(), admin.GetName()), "") // [name] was banned by [admin]
+		c.mu.Lock()
+		c.remove(targetID)
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// Unban allows a user to join again
+func (c *ChatRoom) Unban(admin Obj, targetID string) error {
+	if !c.IsAdmin(admin.GetID()) {
+		return fmt.Errorf("room %s: %s is not an admin", c.Name, admin.GetName())
+	}
+	c.mu.Lock()
+	delete(c.banned, targetID)
+	c.mu.Unlock()
+	return nil
+}
+
+// IsBanned reports whether the user with the given ID may not join
+func (c *ChatRoom) IsBanned(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.banned[id]
+}
+
+// moderate checks mutes and runs the filters over a message
+// A rejected sender is told the reason in their inbox.
+func (c *ChatRoom) moderate(from Obj, text string, private bool) (string, error) {
+	post := &Post{From: from, Text: text, Private: private}
+	var err error
+	if c.IsMuted(from.GetID()) {
+		err = Reject("你已被禁言") // You are muted
+	}
+	for _, filter := range c.Filters {
+		if err != nil {
+			break
+		}
+		err = filter.Apply(c, post)
+	}
+	if err != nil {
+		c.mu.RLock()
+		in, ok := c.member(from.GetID())
+		c.mu.RUnlock()
+		if ok {
+			c.deliver(in, envelope{msg: fmt.Sprintf("[系统] 消息未发送: %s", reason(err))}) // [System] Message not sent
+		}
+		return "", err
+	}
+	if len(post.Flags) > 0 && c.OnFlag != nil {
+		c.OnFlag(*post)
+	}
+	return post.Text, nil
+}
+
+// reason returns the user-facing reason of a rejection
+func reason(err error) string {
+	var rejected *RejectedError
+	if errors.As(err, &rejected) {
+		return rejected.Reason
+	}
+	return err.Error()
+}
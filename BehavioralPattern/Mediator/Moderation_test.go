@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMuteFollowsRoomClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	room := NewChatRoom("Go")
+	room.Now = func() time.Time { return now }
+	admin := newRecorder("管理员", room)
+	member := newRecorder("成员", room)
+	room.AddAdmin(admin.GetID())
+	room.Register(admin)
+	room.Register(member)
+	defer room.Close()
+
+	if err := room.Mute(admin, member.GetID(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !room.IsMuted(member.GetID()) {
+		t.Fatal("member should be muted")
+	}
+	now = now.Add(59 * time.Second)
+	if !room.IsMuted(member.GetID()) {
+		t.Fatal("member should still be muted before the minute is up")
+	}
+	now = now.Add(time.Second)
+	if room.IsMuted(member.GetID()) {
+		t.Fatal("mute should expire by the room clock")
+	}
+}
+
+// moderatedRoom returns a room with an admin and a member using the given filters
+func moderatedRoom(t *testing.T, filters ...Filter) (*ChatRoom, *recorder, *recorder) {
+	t.Helper()
+	room := NewChatRoom("Go")
+	room.Filters = filters
+	admin := newRecorder("管理员", room)
+	member := newRecorder("成员", room)
+	room.AddAdmin(admin.GetID())
+	room.Register(admin)
+	room.Register(member)
+	t.Cleanup(room.Close)
+	return room, admin, member
+}
+
+func TestBannedWordsMaskAndFlag(t *testing.T) {
+	room, admin, member := moderatedRoom(t, BannedWords{Words: []string{"笨蛋", "Bad"}})
+	var flagged []Post
+	room.OnFlag = func(p Post) { flagged = append(flagged, p) }
+
+	if _, err := room.Post(member, "你这个笨蛋, BAD idea"); err != nil {
+		t.Fatal(err)
+	}
+	room.Post(member, "clean")
+	room.Flush()
+	got := admin.received()
+	if len(got) != 2 || got[0] != "你这个**, *** idea" || got[1] != "clean" {
+		t.Fatalf("admin received %q", got)
+	}
+	if len(flagged) != 1 || len(flagged[0].Flags) != 2 || flagged[0].From.GetID() != member.GetID() {
+		t.Fatalf("flagged = %+v, want one post with two flags", flagged)
+	}
+}
+
+func TestLinkBlocker(t *testing.T) {
+	room, admin, member := moderatedRoom(t, LinkBlocker{Allowed: []string{"go.dev"}})
+	for _, text := range []string{"see https://go.dev/doc", "see https://pkg.go.dev/fmt", "no links"} {
+		if _, err := room.Post(member, text); err != nil {
+			t.Fatalf("%q rejected: %v", text, err)
+		}
+	}
+	for _, text := range []string{"see https://evil.example.com", "see www.evil.example.com", "see https://go.dev.evil.com"} {
+		var rejected *RejectedError
+		if _, err := room.Post(member, text); !errors.As(err, &rejected) {
+			t.Fatalf("%q: err = %v, want a RejectedError", text, err)
+		}
+	}
+	room.Flush()
+	if got := len(admin.received()); got != 3 {
+		t.Fatalf("admin received %d messages, want 3", got)
+	}
+	// The sender is told why each message was not sent
+	notes := member.received()
+	if len(notes) != 3 || notes[0] != "[系统] 消息未发送: 不允许发送链接 https://evil.example.com" {
+		t.Fatalf("member received %q", notes)
+	}
+}
+
+func TestLinkBlockerFlagOnly(t *testing.T) {
+	room, admin, member := moderatedRoom(t, LinkBlocker{FlagOnly: true})
+	var flagged []Post
+	room.OnFlag = func(p Post) { flagged = append(flagged, p) }
+	if _, err := room.Post(member, "see https://evil.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	room.Flush()
+	if len(admin.received()) != 1 || len(flagged) != 1 {
+		t.Fatalf("received %q, flagged %+v", admin.received(), flagged)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := NewRateLimit(1, 2)
+	limit.Now = func() time.Time { return now }
+	room, admin, member := moderatedRoom(t, limit)
+
+	for i := 0; i < 2; i++ {
+		if _, err := room.Post(member, "hi"); err != nil {
+			t.Fatalf("burst message %d rejected: %v", i, err)
+		}
+	}
+	if _, err := room.Post(member, "hi"); err == nil {
+		t.Fatal("message beyond the burst was accepted")
+	}
+	// Buckets are per user
+	if _, err := room.Post(admin, "hi"); err != nil {
+		t.Fatalf("another user was limited: %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := room.Post(member, "hi"); err != nil {
+		t.Fatalf("message after refill rejected: %v", err)
+	}
+	room.Flush()
+	if notes := member.received(); len(notes) != 2 || notes[0] != "[系统] 消息未发送: 发言太快，请稍后再试" {
+		t.Fatalf("member received %q", notes)
+	}
+}
+
+func TestMuteBlocksPostsAndDMs(t *testing.T) {
+	room, admin, member := moderatedRoom(t)
+	if err := room.Mute(member, admin.GetID(), 0); err == nil {
+		t.Fatal("a non-admin muted someone")
+	}
+	room.Mute(admin, member.GetID(), 0)
+	if _, err := room.Post(member, "hi"); err == nil {
+		t.Fatal("muted member posted")
+	}
+	if err := room.SendPrivate("hi", member, admin.GetID()); err == nil {
+		t.Fatal("muted member sent a private message")
+	}
+	room.Unmute(admin, member.GetID())
+	if _, err := room.Post(member, "hi"); err != nil {
+		t.Fatalf("unmuted member rejected: %v", err)
+	}
+	room.Flush()
+	if notes := member.received(); len(notes) != 2 || notes[0] != "[系统] 消息未发送: 你已被禁言" {
+		t.Fatalf("member received %q", notes)
+	}
+}
+
+func TestBanRequiresMembership(t *testing.T) {
+	room, admin, member := moderatedRoom(t)
+	if err := room.Ban(admin, "u-stranger"); err == nil {
+		t.Fatal("banned a user who never joined")
+	}
+	// Former members can still be banned after leaving
+	room.Leave(member)
+	if err := room.Ban(admin, member.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	room.Join(member)
+	if room.IsMember(member.GetID()) {
+		t.Fatal("banned user joined again")
+	}
+}
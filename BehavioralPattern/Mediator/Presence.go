@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultAwayAfter is how long a member may stay silent before being shown as away
+const DefaultAwayAfter = time.Minute
+
+// DefaultOfflineAfter is how long a member may stay silent before being shown as offline
+const DefaultOfflineAfter = 5 * time.Minute
+
+// DefaultReceiptCapacity is how many recent messages keep their read receipts
+const DefaultReceiptCapacity = 1000
+
+// ErrUnknownMessage is returned for message IDs the room has no receipts for
+var ErrUnknownMessage = errors.New("unknown message")
+
+// Presence is a member's availability
+type Presence int
+
+const (
+	Offline Presence = iota
+	Online
+	Away
+)
+
+func (p Presence) String() string {
+	switch p {
+	case Online:
+		return "在线" // Online
+	case Away:
+		return "离开" // Away
+	default:
+		return "离线" // Offline
+	}
+}
+
+// Receipt records that a user has read a message
+type Receipt struct {
+	UserID string
+	Name   string
+	At     time.Time
+}
+
+// readLog holds the receipts of one message
+type readLog struct {
+	from     string // Sender ID
+	receipts []Receipt
+}
+
+// now returns the current time of the room's clock
+func (c *ChatRoom) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Heartbeat marks a member as active; an away or offline member comes back online
+func (c *ChatRoom) Heartbeat(user Obj) {
+	c.mu.Lock()
+	in, ok := c.member(user.GetID())
+	back := ok && in.presence != Online
+	if ok {
+		in.lastSeen = c.now()
+		in.presence = Online
+	}
+	c.mu.Unlock()
+	if back {
+		c.broadcast(fmt.Sprintf("[%s] %s 回来了", c.Name, user.GetName()), user.GetID()) // [name] is back
+	}
+}
+
+// CheckPresence marks members that have been silent for AwayAfter as away and
+// those silent for OfflineAfter as offline; offline members also stop typing.
+// Offline members stay in the room and come back online with their next heartbeat.
+// Call it periodically; the other members are told about every change.
+func (c *ChatRoom) CheckPresence() {
+	awayAfter := c.AwayAfter
+	if awayAfter <= 0 {
+		awayAfter = DefaultAwayAfter
+	}
+	offlineAfter := c.OfflineAfter
+	if offlineAfter <= 0 {
+		offlineAfter = DefaultOfflineAfter
+	}
+	now := c.now()
+	var away, offline []Obj
+	c.mu.Lock()
+	for _, in := range c.members {
+		silent := now.Sub(in.lastSeen)
+		switch {
+		case in.presence == Offline:
+		case silent >= offlineAfter:
+			in.presence = Offline
+			in.typing = false
+			offline = append(offline, in.user)
+		case in.presence == Online && silent >= awayAfter:
+			in.presence = Away
+			away = append(away, in.user)
+		}
+	}
+	c.mu.Unlock()
+	for _, user := range away {
+		c.broadcast(fmt.Sprintf("[%s] %s 暂时离开", c.Name, user.GetName()), user.GetID()) // [name] is away
+	}
+	for _, user := range offline {
+		c.broadcast(fmt.Sprintf("[%s] %s 已离线", c.Name, user.GetName()), user.GetID()) // [name] is offline
+	}
+}
+
+// Presence returns whether the user with the given ID is online, away or offline
+func (c *ChatRoom) Presence(id string) Presence {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	in, ok := c.member(id)
+	if !ok {
+		return Offline
+	}
+	return in.presence
+}
+
+// Typing tells the other members that a member started or stopped typing
+// Typing also counts as a heartbeat.
+func (c *ChatRoom) Typing(user Obj, typing bool) {
+	c.Heartbeat(user)
+	c.mu.Lock()
+	in, ok := c.member(user.GetID())
+	changed := ok && in.typing != typing
+	if changed {
+		in.typing = typing
+	}
+	c.mu.Unlock()
+	if !changed {
+		return
+	}
+	if typing {
+		c.broadcast(fmt.Sprintf("[%s] %s 正在输入...", c.Name, user.GetName()), user.GetID()) // [name] is typing...
+	} else {
+		c.broadcast(fmt.Sprintf("[%s] %s 停止输入", c.Name, user.GetName()), user.GetID()) // [name] stopped typing
+	}
+}
+
+// sent records a new message for read receipts; posting also ends typing
+func (c *ChatRoom) sent(user Obj, msg Message) {
+	c.Heartbeat(user)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if in, ok := c.member(user.GetID()); ok {
+		in.typing = false
+	}
+	if c.reads == nil {
+		c.reads = map[string]*readLog{}
+	}
+	c.reads[msg.ID] = &readLog{from: msg.From}
+	c.order = append(c.order, msg.ID)
+	if len(c.order) > DefaultReceiptCapacity {
+		delete(c.reads, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// MarkRead records that a member has read a message
+// Reading a message twice, or one's own message, is not an error.
+func (c *ChatRoom) MarkRead(user Obj, msgID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	log, ok := c.reads[msgID]
+	if !ok {
+		return fmt.Errorf("room %s: %w %s", c.Name, ErrUnknownMessage, msgID)
+	}
+	if _, ok := c.member(user.GetID()); !ok {
+		return fmt.Errorf("room %s: %s is not a member", c.Name, user.GetName())
+	}
+	if log.from == user.GetID() {
+		return nil
+	}
+	for _, receipt := range log.receipts {
+		if receipt.UserID == user.GetID() {
+			return nil
+		}
+	}
+	log.receipts = append(log.receipts, Receipt{UserID: user.GetID(), Name: user.GetName(), At: c.now()})
+	return nil
+}
+
+// Receipts returns who has read a message, in reading order
+// Only the sender of the message may ask.
+func (c *ChatRoom) Receipts(from Obj, msgID string) ([]Receipt, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	log, ok := c.reads[msgID]
+	if !ok {
+		return nil, fmt.Errorf("room %s: %w %s", c.Name, ErrUnknownMessage, msgID)
+	}
+	if log.from != from.GetID() {
+		return nil, fmt.Errorf("room %s: only the sender can see receipts of %s", c.Name, msgID)
+	}
+	return append([]Receipt(nil), log.receipts...), nil
+}
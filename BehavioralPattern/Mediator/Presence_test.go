@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// presenceRoom returns a room on a fake clock with two members
+// Advance the clock through the returned pointer.
+func presenceRoom(t *testing.T) (*ChatRoom, *time.Time, *recorder, *recorder) {
+	t.Helper()
+	clock := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	room := NewChatRoom("办公室")
+	room.Now = func() time.Time { return clock }
+	room.AwayAfter = time.Minute
+	room.OfflineAfter = 5 * time.Minute
+	boss := newRecorder("老王", room)
+	staff := newRecorder("小李", room)
+	room.Register(boss)
+	room.Register(staff)
+	t.Cleanup(room.Close)
+	return room, &clock, boss, staff
+}
+
+func TestPresenceTransitions(t *testing.T) {
+	room, clock, boss, staff := presenceRoom(t)
+	expect := func(want Presence) {
+		t.Helper()
+		room.CheckPresence()
+		if got := room.Presence(staff.GetID()); got != want {
+			t.Fatalf("presence = %v, want %v", got, want)
+		}
+	}
+
+	expect(Online)
+	*clock = clock.Add(time.Minute)
+	expect(Away)
+	*clock = clock.Add(4 * time.Minute)
+	expect(Offline)
+	if !room.IsMember(staff.GetID()) {
+		t.Fatal("a silent member should stay in the room while offline")
+	}
+	staff.Heartbeat()
+	expect(Online)
+
+	// A long silence skips away and goes straight to offline
+	*clock = clock.Add(10 * time.Minute)
+	expect(Offline)
+
+	room.Flush()
+	want := []string{
+		"[办公室] 小李 暂时离开",
+		"[办公室] 小李 已离线",
+		"[办公室] 小李 回来了",
+		"[办公室] 小李 已离线",
+	}
+	if got := boss.received(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("boss saw %q, want %q", got, want)
+	}
+}
+
+func TestPresenceOfStranger(t *testing.T) {
+	room, _, _, _ := presenceRoom(t)
+	if got := room.Presence("nobody"); got != Offline {
+		t.Fatalf("presence of a non-member = %v, want Offline", got)
+	}
+}
+
+func TestTypingBroadcastsChanges(t *testing.T) {
+	room, _, boss, staff := presenceRoom(t)
+	staff.SetTyping(true)
+	staff.SetTyping(true) // Unchanged, not broadcast again
+	staff.SetTyping(false)
+	staff.SetTyping(true)
+	if _, err := room.Post(staff, "报告写完了"); err != nil {
+		t.Fatal(err)
+	}
+	staff.SetTyping(false) // Posting already ended typing
+	room.Flush()
+
+	want := []string{
+		"[办公室] 小李 正在输入...",
+		"[办公室] 小李 停止输入",
+		"[办公室] 小李 正在输入...",
+	}
+	got := boss.received()
+	if len(got) != len(want)+1 || !reflect.DeepEqual(got[:len(want)], want) {
+		t.Fatalf("boss saw %q, want %q followed by the post", got, want)
+	}
+	for _, msg := range staff.received() {
+		if msg == want[0] || msg == want[1] {
+			t.Fatalf("typer should not see their own indicator, got %q", msg)
+		}
+	}
+}
+
+func TestOfflineEndsTyping(t *testing.T) {
+	room, clock, boss, staff := presenceRoom(t)
+	staff.SetTyping(true)
+	*clock = clock.Add(5 * time.Minute)
+	boss.Heartbeat()
+	room.CheckPresence()
+	staff.SetTyping(true) // Comes back online and starts typing again
+	room.Flush()
+
+	want := []string{
+		"[办公室] 小李 正在输入...",
+		"[办公室] 小李 已离线",
+		"[办公室] 小李 回来了",
+		"[办公室] 小李 正在输入...",
+	}
+	if got := boss.received(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("boss saw %q, want %q", got, want)
+	}
+}
+
+func TestReceipts(t *testing.T) {
+	room, clock, boss, staff := presenceRoom(t)
+	third := newRecorder("小张", room)
+	room.Register(third)
+	msgID, err := room.Post(staff, "报告写完了")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*clock = clock.Add(time.Minute)
+	if err := boss.MarkRead(msgID); err != nil {
+		t.Fatal(err)
+	}
+	*clock = clock.Add(time.Minute)
+	if err := boss.MarkRead(msgID); err != nil {
+		t.Fatalf("reading twice = %v, want nil", err)
+	}
+	if err := staff.MarkRead(msgID); err != nil {
+		t.Fatalf("reading one's own message = %v, want nil", err)
+	}
+	if err := third.MarkRead(msgID); err != nil {
+		t.Fatal(err)
+	}
+
+	receipts, err := room.Receipts(staff, msgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	want := []Receipt{
+		{UserID: boss.GetID(), Name: "老王", At: start.Add(time.Minute)},
+		{UserID: third.GetID(), Name: "小张", At: start.Add(2 * time.Minute)},
+	}
+	if !reflect.DeepEqual(receipts, want) {
+		t.Fatalf("receipts = %+v, want %+v", receipts, want)
+	}
+
+	if _, err := room.Receipts(boss, msgID); err == nil {
+		t.Fatal("only the sender should see the receipts")
+	}
+	if _, err := room.Receipts(staff, "missing"); !errors.Is(err, ErrUnknownMessage) {
+		t.Fatalf("receipts of an unknown message = %v, want ErrUnknownMessage", err)
+	}
+	if err := boss.MarkRead("missing"); !errors.Is(err, ErrUnknownMessage) {
+		t.Fatalf("reading an unknown message = %v, want ErrUnknownMessage", err)
+	}
+}
+
+func TestMarkReadRequiresMembership(t *testing.T) {
+	room, _, _, staff := presenceRoom(t)
+	msgID, err := room.Post(staff, "报告写完了")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outsider := newRecorder("路人", room)
+	if err := outsider.MarkRead(msgID); err == nil {
+		t.Fatal("a non-member should not leave a receipt")
+	}
+	receipts, err := room.Receipts(staff, msgID)
+	if err != nil || len(receipts) != 0 {
+		t.Fatalf("receipts = %v, %v, want none", receipts, err)
+	}
+}
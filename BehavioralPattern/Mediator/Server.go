@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// transport is a message-oriented connection to a remote chat client
+type transport interface {
+	ReadMessage() (string, error)
+	WriteMessage(msg string) error
+	Close() error
+}
+
+// lineConn implements transport over a raw TCP connection, one message per line
+type lineConn struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	writeMu sync.Mutex
+}
+
+// newLineConn wraps a TCP connection with the line protocol
+// Lines are limited to maxMessageSize like WebSocket messages.
+func newLineConn(conn net.Conn) *lineConn {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxMessageSize)
+	return &lineConn{conn: conn, scanner: scanner}
+}
+
+// ReadMessage reads one line without its line ending
+// A line longer than maxMessageSize fails with bufio.ErrTooLong.
+func (l *lineConn) ReadMessage() (string, error) {
+	if !l.scanner.Scan() {
+		if err := l.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return l.scanner.Text(), nil
+}
+
+// WriteMessage writes msg followed by a newline
+func (l *lineConn) WriteMessage(msg string) error {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	_, err := fmt.Fprintf(l.conn, "%s\n", msg)
+	return err
+}
+
+// Close closes the TCP connection
+func (l *lineConn) Close() error {
+	return l.conn.Close()
+}
+
+// RemoteUser is a chat participant connected over the network
+type RemoteUser struct {
+	Name     string    // User's name
+	id       string    // Unique identifier
+	mediator Mediator  // Reference to the mediator
+	conn     transport // Connection to the client
+}
+
+// GetID returns the user's unique identifier
+func (r *RemoteUser) GetID() string {
+	return r.id
+}
+
+// GetName returns the user's name
+func (r *RemoteUser) GetName() string {
+	return r.Name
+}
+
+// SendMsg sends a message from the remote user through the mediator
+func (r *RemoteUser) SendMsg(msg string) {
+	r.mediator.SendMsg(msg, r)
+}
+
+// RevMsg delivers a message to the remote client
+func (r *RemoteUser) RevMsg(msg string) {
+	r.conn.WriteMessage(msg)
+}
+
+// RevMessage delivers a chat message prefixed with its ID and sender, so the
+// client can send "/read <id>"
+func (r *RemoteUser) RevMessage(msg Message) {
+	r.conn.WriteMessage(fmt.Sprintf("#%s %s: %s", msg.ID, msg.Name, msg.Text))
+}
+
+// Heartbeat tells the mediator the remote user is still active
+func (r *RemoteUser) Heartbeat() {
+	r.mediator.Heartbeat(r)
+}
+
+// SetTyping tells the mediator the remote user started or stopped typing
+func (r *RemoteUser) SetTyping(typing bool) {
+	r.mediator.Typing(r, typing)
+}
+
+// MarkRead tells the mediator the remote user has read a message
+func (r *RemoteUser) MarkRead(msgID string) error {
+	return r.mediator.MarkRead(r, msgID)
+}
+
+// Close disconnects the remote client
+func (r *RemoteUser) Close() error {
+	return r.conn.Close()
+}
+
+// Server exposes a ChatRoom to clients over TCP and WebSocket
+// The ChatRoom still mediates every message; the server only translates
+// between connections and RemoteUser colleagues.
+type Server struct {
+	// AllowedOrigins lists the origins, such as "https://chat.example.com", whose
+	// pages may open WebSocket connections besides pages served from the same host
+	AllowedOrigins []string
+
+	room *ChatRoom
+}
+
+// NewServer creates a server for the given room
+func NewServer(room *ChatRoom) *Server {
+	return &Server{room: room}
+}
+
+// ServeTCP accepts line-protocol clients until the listener is closed
+func (s *Server) ServeTCP(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serve(newLineConn(conn))
+	}
+}
+
+// ServeHTTP upgrades requests to WebSocket and serves them as chat clients
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r, s.AllowedOrigins)
+	if err != nil {
+		return
+	}
+	s.serve(conn)
+}
+
+// serve runs one client session: the first message is the user's name,
+// every following message is sent to the room until "/quit" or disconnect.
+// "/msg <id> <text>" sends a private message to another member, "/typing" and
+// "/idle" toggle the typing indicator, "/read <id>" marks a message as read and
+// "/receipts <id>" lists who has read one of the user's messages.
+// Every message from the client counts as a heartbeat.
+func (s *Server) serve(conn transport) {
+	defer conn.Close()
+	conn.WriteMessage("请输入用户名") // Please enter your name
+	name, err := conn.ReadMessage()
+	name = strings.TrimSpace(name)
+	if err != nil || name == "" {
+		return
+	}
+
+	user := &RemoteUser{Name: name, id: NewUserID(), mediator: s.room, conn: conn}
+	conn.WriteMessage("你的 ID 是 " + user.id) // Your ID is
+	s.room.Join(user)
+	defer s.room.Leave(user)
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil || msg == "/quit" {
+			return
+		}
+		user.Heartbeat()
+		if strings.TrimSpace(msg) == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(msg, "/msg "); ok {
+			toID, text, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			if err := s.room.SendPrivate(text, user, toID); err != nil {
+				conn.WriteMessage(err.Error())
+			}
+			continue
+		}
+		if s.command(user, msg) {
+			continue
+		}
+		id, err := s.room.Post(user, msg)
+		if err == nil {
+			conn.WriteMessage("#" + id) // Lets the sender ask for receipts
+		}
+	}
+}
+
+// command handles the presence and receipt commands, reporting whether msg was one
+func (s *Server) command(user *RemoteUser, msg string) bool {
+	verb, arg, _ := strings.Cut(msg, " ")
+	arg = strings.TrimSpace(arg)
+	switch verb {
+	case "/typing":
+		user.SetTyping(true)
+	case "/idle":
+		user.SetTyping(false)
+	case "/read":
+		if err := user.MarkRead(arg); err != nil {
+			user.RevMsg(err.Error())
+		}
+	case "/receipts":
+		receipts, err := s.room.Receipts(user, arg)
+		if err != nil {
+			user.RevMsg(err.Error())
+			return true
+		}
+		names := make([]string, len(receipts))
+		for i, receipt := range receipts {
+			names[i] = receipt.Name
+		}
+		user.RevMsg(fmt.Sprintf("%s 已读: %s", arg, strings.Join(names, ", "))) // Read by
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tcpClient is a line-protocol test client
+type tcpClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialChat connects to the server and logs in with name
+func dialChat(t *testing.T, addr, name string) *tcpClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	c := &tcpClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	c.expect(func(line string) bool { return line == "请输入用户名" })
+	c.send(name)
+	c.expect(func(line string) bool { return strings.HasPrefix(line, "你的 ID 是 ") })
+	return c
+}
+
+func (c *tcpClient) send(line string) {
+	c.t.Helper()
+	if _, err := fmt.Fprintf(c.conn, "%s\n", line); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads lines until one satisfies match and returns it
+func (c *tcpClient) expect(match func(string) bool) string {
+	c.t.Helper()
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("waiting for a line: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if match(line) {
+			return line
+		}
+	}
+}
+
+func TestServerRendersSenderNameOnce(t *testing.T) {
+	room := NewChatRoom("大厅")
+	room.History = NewHistory(10)
+	room.Replay = 5
+	defer room.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go NewServer(room).ServeTCP(listener)
+	addr := listener.Addr().String()
+
+	alice := dialChat(t, addr, "张三")
+	bob := dialChat(t, addr, "李四")
+	alice.expect(func(line string) bool { return strings.Contains(line, "李四 加入了房间") })
+	alice.send("今天有龙井")
+	ack := alice.expect(func(line string) bool { return strings.HasPrefix(line, "#m") })
+
+	got := bob.expect(func(line string) bool { return strings.HasPrefix(line, "#m") })
+	if got != ack+" 张三: 今天有龙井" {
+		t.Fatalf("bob received %q, want the name once before the text", got)
+	}
+	if last := room.History.Last(1); len(last) != 1 || last[0].Text != "今天有龙井" || last[0].Name != "张三" {
+		t.Fatalf("history = %+v, want the raw text", last)
+	}
+
+	carol := dialChat(t, addr, "王五")
+	replay := carol.expect(func(line string) bool { return strings.HasPrefix(line, "[历史 ") })
+	if !strings.HasSuffix(replay, "] 张三: 今天有龙井") {
+		t.Fatalf("replay = %q, want the name once", replay)
+	}
+}
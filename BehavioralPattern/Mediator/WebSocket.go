@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocketGUID is the fixed GUID from RFC 6455 used to compute Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize limits the size of a single (possibly fragmented) message
+const maxMessageSize = 64 * 1024
+
+// WebSocket opcodes defined by RFC 6455
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// errWebSocketClosed is returned once the peer has sent a close frame
+var errWebSocketClosed = errors.New("websocket: closed by peer")
+
+// wsConn is a server-side WebSocket connection implementing transport
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex // Serializes frames written by different goroutines
+	closed  bool       // A close frame was sent, guarded by writeMu
+}
+
+// upgradeWebSocket performs the RFC 6455 opening handshake and hijacks the connection
+// Browsers send an Origin header; it must match the request's host or one of
+// allowedOrigins, so other sites cannot open connections with the user's cookies.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, allowedOrigins []string) (*wsConn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a websocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if !checkOrigin(r, allowedOrigins) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("websocket: origin %s not allowed", r.Header.Get("Origin"))
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid key")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+// checkOrigin reports whether the request's Origin is the server itself or allowed
+// Requests without an Origin come from non-browser clients and are accepted.
+func checkOrigin(r *http.Request, allowedOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// headerContains reports whether a comma-separated header contains token
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readFrame reads a single frame and unmasks its payload
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		err = errors.New("websocket: reserved bits set")
+		return
+	}
+	masked := header[1]&0x80 != 0
+	if !masked {
+		// Clients must mask every frame they send
+		err = errors.New("websocket: unmasked client frame")
+		return
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		err = errors.New("websocket: invalid control frame")
+		return
+	}
+	if length > maxMessageSize {
+		err = errors.New("websocket: frame too large")
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// writeFrame writes a single unfragmented, unmasked server frame
+// Nothing is written after a close frame, and only one close frame is sent.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+	if opcode == opClose {
+		c.closed = true
+	}
+
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// ReadMessage returns the next text or binary message, reassembling fragments
+// and answering pings along the way
+func (c *wsConn) ReadMessage() (string, error) {
+	var message []byte
+	fragmented := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return "", err
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return "", err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			// Echo the status code back to complete the closing handshake
+			if len(payload) >= 2 {
+				payload = payload[:2]
+			}
+			c.writeFrame(opClose, payload)
+			return "", errWebSocketClosed
+		case opText, opBinary:
+			if fragmented {
+				return "", errors.New("websocket: expected continuation frame")
+			}
+			message = payload
+		case opContinuation:
+			if !fragmented {
+				return "", errors.New("websocket: unexpected continuation frame")
+			}
+			message = append(message, payload...)
+		default:
+			return "", fmt.Errorf("websocket: unknown opcode %#x", opcode)
+		}
+		if len(message) > maxMessageSize {
+			return "", errors.New("websocket: message too large")
+		}
+		if fin {
+			return string(message), nil
+		}
+		fragmented = true
+	}
+}
+
+// WriteMessage sends a text message
+func (c *wsConn) WriteMessage(msg string) error {
+	return c.writeFrame(opText, []byte(msg))
+}
+
+// Close sends a normal closure frame and closes the connection
+func (c *wsConn) Close() error {
+	// Unblock a writer stuck on a client that stopped reading
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.writeFrame(opClose, []byte{0x03, 0xE8}) // 1000 Normal Closure
+	return c.conn.Close()
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsPipe returns a server-side wsConn and the client end of an in-memory connection
+func wsPipe(t *testing.T) (*wsConn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return &wsConn{conn: server, reader: bufio.NewReader(server)}, client
+}
+
+// clientFrame builds a frame as a client would send it, masked unless told otherwise
+func clientFrame(fin bool, opcode byte, payload []byte, masked bool) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	if !masked {
+		return append(frame, payload...)
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// sendFrames writes frames from the client in the background
+func sendFrames(client net.Conn, frames ...[]byte) {
+	go func() {
+		for _, frame := range frames {
+			if _, err := client.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// readServerFrame reads one unmasked frame sent by the server
+func readServerFrame(t *testing.T, client net.Conn) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(client, header[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("server frame is masked")
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(client, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(client, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// readMessage runs ReadMessage in the background, since it may write replies
+func readMessage(ws *wsConn) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		msg, err := ws.ReadMessage()
+		if err == nil && msg != "hello" {
+			err = fmt.Errorf("message = %q, want hello", msg)
+		}
+		result <- err
+	}()
+	return result
+}
+
+func TestWebSocketFragmentsAndPing(t *testing.T) {
+	ws, client := wsPipe(t)
+	sendFrames(client,
+		clientFrame(false, opText, []byte("he"), true),
+		clientFrame(true, opPing, []byte("p"), true), // Control frames may be interleaved
+		clientFrame(true, opContinuation, []byte("llo"), true),
+	)
+	result := readMessage(ws)
+	if opcode, payload := readServerFrame(t, client); opcode != opPong || string(payload) != "p" {
+		t.Fatalf("reply = %#x %q, want pong p", opcode, payload)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebSocketRejectsInvalidFrames(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"unmasked", [][]byte{clientFrame(true, opText, []byte("hello"), false)}},
+		{"reserved bits", [][]byte{append([]byte{0xC1}, clientFrame(true, opText, nil, true)[1:]...)}},
+		{"fragmented control frame", [][]byte{clientFrame(false, opPing, nil, true)}},
+		{"long control frame", [][]byte{clientFrame(true, opPing, make([]byte, 126), true)}},
+		{"oversized frame", [][]byte{clientFrame(true, opBinary, make([]byte, maxMessageSize+1), true)}},
+		{"oversized message", [][]byte{
+			clientFrame(false, opText, make([]byte, maxMessageSize/2+1), true),
+			clientFrame(true, opContinuation, make([]byte, maxMessageSize/2+1), true),
+		}},
+		{"continuation without start", [][]byte{clientFrame(true, opContinuation, []byte("x"), true)}},
+		{"new message inside fragments", [][]byte{
+			clientFrame(false, opText, []byte("a"), true),
+			clientFrame(true, opText, []byte("b"), true),
+		}},
+		{"unknown opcode", [][]byte{clientFrame(true, 0x3, nil, true)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ws, client := wsPipe(t)
+			sendFrames(client, tc.frames...)
+			if err := <-readMessage(ws); err == nil {
+				t.Fatal("ReadMessage accepted an invalid frame")
+			}
+		})
+	}
+}
+
+func TestWebSocketCloseHandshake(t *testing.T) {
+	ws, client := wsPipe(t)
+	sendFrames(client, clientFrame(true, opClose, []byte{0x03, 0xE8, 'b', 'y', 'e'}, true))
+	result := readMessage(ws)
+	if opcode, payload := readServerFrame(t, client); opcode != opClose || !bytes.Equal(payload, []byte{0x03, 0xE8}) {
+		t.Fatalf("reply = %#x %v, want the echoed close code", opcode, payload)
+	}
+	if err := <-result; !errors.Is(err, errWebSocketClosed) {
+		t.Fatalf("ReadMessage = %v, want errWebSocketClosed", err)
+	}
+
+	// Closing after the handshake must not send a second close frame
+	go ws.Close()
+	if n, err := client.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("read after Close = %d bytes, %v, want EOF", n, err)
+	}
+	if err := ws.WriteMessage("late"); err == nil {
+		t.Fatal("WriteMessage succeeded after the close frame")
+	}
+}
+
+func TestWebSocketCloseSendsNormalClosure(t *testing.T) {
+	ws, client := wsPipe(t)
+	go ws.Close()
+	if opcode, payload := readServerFrame(t, client); opcode != opClose || !bytes.Equal(payload, []byte{0x03, 0xE8}) {
+		t.Fatalf("frame = %#x %v, want close 1000", opcode, payload)
+	}
+}
+
+// handshake sends an upgrade request with the given Origin and returns the status line
+func handshake(t *testing.T, addr, origin string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	request := "GET / HTTP/1.1\r\nHost: " + addr + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+	if origin != "" {
+		request += "Origin: " + origin + "\r\n"
+	}
+	fmt.Fprint(conn, request+"\r\n")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(status)
+}
+
+func TestWebSocketChecksOrigin(t *testing.T) {
+	room := NewChatRoom("test")
+	defer room.Close()
+	server := NewServer(room)
+	server.AllowedOrigins = []string{"https://chat.example.com"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "http://")
+
+	for origin, want := range map[string]string{
+		"":                         "101",
+		"http://" + addr:           "101",
+		"https://chat.example.com": "101",
+		"https://evil.example.com": "403",
+		"null":                     "403",
+	} {
+		if status := handshake(t, addr, origin); !strings.Contains(status, " "+want+" ") {
+			t.Errorf("origin %q: status %q, want %s", origin, status, want)
+		}
+	}
+}
+
+func TestLineConn(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	line := newLineConn(server)
+	defer line.Close()
+	go fmt.Fprint(client, "hello\r\nworld\n"+strings.Repeat("x", maxMessageSize+1)+"\n")
+
+	for _, want := range []string{"hello", "world"} {
+		if msg, err := line.ReadMessage(); err != nil || msg != want {
+			t.Fatalf("ReadMessage = %q, %v, want %q", msg, err, want)
+		}
+	}
+	if _, err := line.ReadMessage(); !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatalf("oversized line: err = %v, want bufio.ErrTooLong", err)
+	}
+
+	go line.WriteMessage("reply")
+	reply, _ := bufio.NewReader(client).ReadString('\n')
+	if reply != "reply\n" {
+		t.Fatalf("written line = %q", reply)
+	}
+}
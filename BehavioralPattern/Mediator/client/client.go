@@ -0,0 +1,56 @@
+/*
+Chat client for the networked Mediator ChatRoom
+
+Connects to the server's line-protocol TCP port, prints every message
+the room delivers and sends each line typed on the terminal.
+
+Usage:
+
+	go run ./BehavioralPattern/Mediator/client -addr localhost:9000 -name 枫枫
+*/
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "chat server TCP address")
+	name := flag.String("name", "", "user name, prompted by the server when empty")
+	flag.Parse()
+
+	conn, err := net.Dial("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	// Print everything the room sends us
+	done := make(chan struct{})
+	go func() {
+		io.Copy(os.Stdout, conn)
+		close(done)
+	}()
+
+	if *name != "" {
+		fmt.Fprintln(conn, *name)
+	}
+	// Forward terminal input line by line; "/quit" leaves the room
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(conn, scanner.Text()); err != nil {
+			break
+		}
+		if scanner.Text() == "/quit" {
+			break
+		}
+	}
+	<-done
+}
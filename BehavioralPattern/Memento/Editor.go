@@ -0,0 +1,53 @@
+package main
+
+// Editor wires a TextNode to a Manage caretaker so edits can be undone and redone
+type Editor struct {
+	Node    *TextNode
+	History *Manage
+}
+
+// NewEditor creates an editor keeping at most capacity states, unlimited when zero
+// The empty initial text is the first state.
+func NewEditor(capacity int) *Editor {
+	e := &Editor{
+		Node:    &TextNode{},
+		History: &Manage{Capacity: capacity},
+	}
+	e.History.Save(e.Node.Save())
+	return e
+}
+
+// Type replaces the text and records it as a new state
+func (e *Editor) Type(text string) {
+	e.Node.SetState(text)
+	e.History.Save(e.Node.Save())
+}
+
+// Text returns the current text
+func (e *Editor) Text() string {
+	return e.Node.GetState()
+}
+
+// Undo restores the previous state
+func (e *Editor) Undo() error {
+	return e.restore(e.History.Undo())
+}
+
+// Redo restores the state that was last undone
+func (e *Editor) Redo() error {
+	return e.restore(e.History.Redo())
+}
+
+// Jump restores the state at index in the history
+func (e *Editor) Jump(index int) error {
+	return e.restore(e.History.Jump(index))
+}
+
+// restore applies a memento returned by the caretaker
+func (e *Editor) restore(m Memento, err error) error {
+	if err != nil {
+		return err
+	}
+	e.Node.Restore(m)
+	return nil
+}
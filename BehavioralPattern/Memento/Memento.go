@@ -20,7 +20,10 @@ Benefits:
 
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // Node defines the interface for objects that can have their state managed
 type Node interface {
@@ -50,6 +53,11 @@ func (t *TextNode) Save() Memento {
 	}
 }
 
+// Restore sets the state saved in a memento
+func (t *TextNode) Restore(m Memento) {
+	t.state = m.GetState()
+}
+
 // Memento defines the interface for accessing saved states
 type Memento interface {
 	GetState() string // Get the saved state
@@ -65,19 +73,93 @@ func (t TextMemento) GetState() string {
 	return t.state
 }
 
+// Errors returned by Manage instead of panicking
+var (
+	ErrNothingToUndo = errors.New("memento: nothing to undo")
+	ErrNothingToRedo = errors.New("memento: nothing to redo")
+	ErrOutOfRange    = errors.New("memento: index out of range")
+)
+
 // Manage acts as the caretaker for mementos
+// It keeps a cursor on the current state so callers can step back and forth.
+// Saving after an undo discards the states that could have been redone.
 type Manage struct {
-	states []Memento // Collection of saved states
+	Capacity int // Maximum number of mementos kept, unlimited when zero
+
+	states []Memento // Collection of saved states, oldest first
+	pos    int       // Number of states up to and including the current one
 }
 
-// Save stores a memento
+// Save stores a memento as the new current state
+// The oldest memento is evicted when the capacity is exceeded.
 func (m *Manage) Save(t Memento) {
-	m.states = append(m.states, t)
+	m.states = append(m.states[:m.pos], t)
+	if m.Capacity > 0 && len(m.states) > m.Capacity {
+		evicted := len(m.states) - m.Capacity
+		m.states = append([]Memento(nil), m.states[evicted:]...)
+	}
+	m.pos = len(m.states)
+}
+
+// Back retrieves a memento at a specific index without moving the cursor
+func (m *Manage) Back(index int) (Memento, error) {
+	if index < 0 || index >= len(m.states) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrOutOfRange, index, len(m.states))
+	}
+	return m.states[index], nil
+}
+
+// Current returns the memento at the cursor
+func (m *Manage) Current() (Memento, error) {
+	return m.Back(m.pos - 1)
+}
+
+// Undo moves the cursor to the previous memento and returns it
+func (m *Manage) Undo() (Memento, error) {
+	if !m.CanUndo() {
+		return nil, ErrNothingToUndo
+	}
+	m.pos--
+	return m.states[m.pos-1], nil
+}
+
+// Redo moves the cursor to the next memento and returns it
+func (m *Manage) Redo() (Memento, error) {
+	if !m.CanRedo() {
+		return nil, ErrNothingToRedo
+	}
+	m.pos++
+	return m.states[m.pos-1], nil
+}
+
+// Jump moves the cursor to the memento at index and returns it
+func (m *Manage) Jump(index int) (Memento, error) {
+	state, err := m.Back(index)
+	if err != nil {
+		return nil, err
+	}
+	m.pos = index + 1
+	return state, nil
+}
+
+// CanUndo reports whether there is an earlier memento
+func (m *Manage) CanUndo() bool {
+	return m.pos > 1
+}
+
+// CanRedo reports whether an undone memento can be restored
+func (m *Manage) CanRedo() bool {
+	return m.pos < len(m.states)
 }
 
-// Back retrieves a memento at a specific index
-func (m *Manage) Back(index int) Memento {
-	return m.states[index]
+// Len returns the number of mementos kept
+func (m *Manage) Len() int {
+	return len(m.states)
+}
+
+// Position returns the index of the current memento, -1 when nothing is saved
+func (m *Manage) Position() int {
+	return m.pos - 1
 }
 
 // Example usage of the Memento Pattern
@@ -99,5 +181,61 @@ func main() {
 	// Show current state
 	fmt.Println(text.GetState())
 	// Show restored state from first save
-	fmt.Println(manage.Back(0).GetState())
+	first, _ := manage.Back(0)
+	fmt.Println(first.GetState())
+
+	// An editor steps back and forth through a bounded history
+	editor := NewEditor(3)
+	for _, state := range []string{"a", "ab", "abc", "abcd"} {
+		editor.Type(state)
+	}
+	editor.Undo()
+	editor.Undo()
+	fmt.Println(editor.Text()) // ab
+	editor.Redo()
+	fmt.Println(editor.Text()) // abc
+	if err := editor.Undo(); err == nil {
+		err = editor.Undo() // "a" was evicted
+		fmt.Println(editor.Text(), err)
+	}
+	if err := editor.Jump(5); err != nil {
+		fmt.Println(err)
+	}
+	editor.Jump(2)
+	fmt.Println(editor.Text()) // abcd
+	editor.Undo()
+	editor.Type("abX") // Discards the redo history
+	fmt.Println(editor.Text(), editor.History.CanRedo())
+
+	// Any domain object can be checkpointed with a generic snapshot
+	doc := &Document{
+		Title: "设计文档",                        // Design document
+		Tags:  []string{"草稿"},                // Draft
+		Meta:  map[string]string{"作者": "枫枫"}, // Author
+	}
+	doc.Sections = []*Section{{Heading: "背景", Doc: doc}} // Background
+	checkpoint := TakeSnapshot(doc)
+
+	doc.Title = "设计文档 v2"
+	doc.Tags[0] = "定稿" // Final
+	doc.Meta["作者"] = "张三"
+	doc.Sections[0].Heading = "动机" // Motivation
+
+	restored := checkpoint.Restore()
+	fmt.Println(restored.Title, restored.Tags, restored.Meta["作者"], restored.Sections[0].Heading)
+	fmt.Println(restored.Sections[0].Doc == restored, restored != doc) // The cycle points at the copy
+}
+
+// Document is an example domain object with nested references
+type Document struct {
+	Title    string
+	Tags     []string
+	Meta     map[string]string
+	Sections []*Section
+}
+
+// Section belongs to a Document and points back to it
+type Section struct {
+	Heading string
+	Doc     *Document
 }
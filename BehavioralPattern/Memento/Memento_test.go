@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// saveAll saves a TextMemento for each state
+func saveAll(m *Manage, states ...string) {
+	for _, state := range states {
+		m.Save(TextMemento{state: state})
+	}
+}
+
+// expectState fails unless the memento holds want
+func expectState(t *testing.T, m Memento, err error, want string) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetState(); got != want {
+		t.Fatalf("state = %q, want %q", got, want)
+	}
+}
+
+func TestManageEmpty(t *testing.T) {
+	var m Manage
+	if _, err := m.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("Undo = %v, want ErrNothingToUndo", err)
+	}
+	if _, err := m.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("Redo = %v, want ErrNothingToRedo", err)
+	}
+	if _, err := m.Current(); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("Current = %v, want ErrOutOfRange", err)
+	}
+	if m.Position() != -1 || m.Len() != 0 {
+		t.Fatalf("position %d, len %d, want -1 and 0", m.Position(), m.Len())
+	}
+}
+
+func TestManageUndoRedoEdges(t *testing.T) {
+	var m Manage
+	saveAll(&m, "1", "2", "3")
+
+	if _, err := m.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("Redo at the newest state = %v, want ErrNothingToRedo", err)
+	}
+	state, err := m.Undo()
+	expectState(t, state, err, "2")
+	state, err = m.Undo()
+	expectState(t, state, err, "1")
+	if _, err := m.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("Undo at the oldest state = %v, want ErrNothingToUndo", err)
+	}
+	state, err = m.Current()
+	expectState(t, state, err, "1")
+
+	state, err = m.Redo()
+	expectState(t, state, err, "2")
+	state, err = m.Redo()
+	expectState(t, state, err, "3")
+	if m.CanRedo() || !m.CanUndo() {
+		t.Fatalf("CanRedo %v, CanUndo %v at the newest state", m.CanRedo(), m.CanUndo())
+	}
+}
+
+func TestManageJump(t *testing.T) {
+	var m Manage
+	saveAll(&m, "1", "2", "3")
+
+	for _, index := range []int{-1, 3} {
+		if _, err := m.Jump(index); !errors.Is(err, ErrOutOfRange) {
+			t.Fatalf("Jump(%d) = %v, want ErrOutOfRange", index, err)
+		}
+		if m.Position() != 2 {
+			t.Fatalf("a failed Jump moved the cursor to %d", m.Position())
+		}
+	}
+	state, err := m.Jump(0)
+	expectState(t, state, err, "1")
+	if m.Position() != 0 || !m.CanRedo() {
+		t.Fatalf("after Jump(0) position %d, CanRedo %v", m.Position(), m.CanRedo())
+	}
+	state, err = m.Redo()
+	expectState(t, state, err, "2")
+}
+
+func TestManageSaveDiscardsRedo(t *testing.T) {
+	var m Manage
+	saveAll(&m, "1", "2", "3")
+	m.Undo()
+	m.Undo()
+	saveAll(&m, "X")
+
+	if m.CanRedo() {
+		t.Fatal("saving after undo should discard the redo states")
+	}
+	if m.Len() != 2 {
+		t.Fatalf("len = %d, want 2", m.Len())
+	}
+	state, err := m.Back(1)
+	expectState(t, state, err, "X")
+	state, err = m.Undo()
+	expectState(t, state, err, "1")
+}
+
+func TestManageCapacityEvictsOldest(t *testing.T) {
+	m := Manage{Capacity: 3}
+	saveAll(&m, "1", "2", "3", "4", "5")
+
+	if m.Len() != 3 || m.Position() != 2 {
+		t.Fatalf("len %d, position %d, want 3 and 2", m.Len(), m.Position())
+	}
+	for index, want := range []string{"3", "4", "5"} {
+		state, err := m.Back(index)
+		expectState(t, state, err, want)
+	}
+	m.Undo()
+	m.Undo()
+	if _, err := m.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("Undo past the evicted states = %v, want ErrNothingToUndo", err)
+	}
+
+	// Saving from the middle drops the redo states before anything is evicted
+	saveAll(&m, "X")
+	if m.Len() != 2 {
+		t.Fatalf("len = %d, want 2", m.Len())
+	}
+}
+
+func TestEditor(t *testing.T) {
+	editor := NewEditor(3)
+	if editor.Text() != "" || editor.History.Len() != 1 {
+		t.Fatal("a new editor should start with the empty text saved")
+	}
+	for _, text := range []string{"a", "ab", "abc"} {
+		editor.Type(text)
+	}
+	// "" was evicted, so "a" is the oldest state
+	if err := editor.Undo(); err != nil || editor.Text() != "ab" {
+		t.Fatalf("Undo = %v, text %q", err, editor.Text())
+	}
+	if err := editor.Undo(); err != nil || editor.Text() != "a" {
+		t.Fatalf("Undo = %v, text %q", err, editor.Text())
+	}
+	if err := editor.Undo(); !errors.Is(err, ErrNothingToUndo) || editor.Text() != "a" {
+		t.Fatalf("Undo = %v, text %q, want ErrNothingToUndo and unchanged text", err, editor.Text())
+	}
+	if err := editor.Jump(3); !errors.Is(err, ErrOutOfRange) || editor.Text() != "a" {
+		t.Fatalf("Jump = %v, text %q, want ErrOutOfRange and unchanged text", err, editor.Text())
+	}
+	if err := editor.Jump(2); err != nil || editor.Text() != "abc" {
+		t.Fatalf("Jump = %v, text %q", err, editor.Text())
+	}
+	editor.Undo()
+	editor.Type("abX")
+	if err := editor.Redo(); !errors.Is(err, ErrNothingToRedo) || editor.Text() != "abX" {
+		t.Fatalf("Redo = %v, text %q, want ErrNothingToRedo after typing", err, editor.Text())
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// Snapshot is a memento holding a deep copy of any value
+// Unlike TextMemento it needs no hand-written type per originator.
+type Snapshot[T any] struct {
+	value T
+	taken time.Time
+}
+
+// TakeSnapshot deep-copies v, so later changes to v do not affect the snapshot
+func TakeSnapshot[T any](v T) *Snapshot[T] {
+	return &Snapshot[T]{value: DeepCopy(v), taken: time.Now()}
+}
+
+// Restore returns a fresh deep copy of the saved value
+// The snapshot can be restored any number of times.
+func (s *Snapshot[T]) Restore() T {
+	return DeepCopy(s.value)
+}
+
+// RestoreInto overwrites *dst with a copy of the saved value
+func (s *Snapshot[T]) RestoreInto(dst *T) {
+	*dst = s.Restore()
+}
+
+// Taken returns when the snapshot was taken
+func (s *Snapshot[T]) Taken() time.Time {
+	return s.taken
+}
+
+// GetState describes the saved value, so snapshots can be kept by Manage
+func (s *Snapshot[T]) GetState() string {
+	return fmt.Sprintf("%+v", s.value)
+}
+
+// DeepCopy copies v recursively, following pointers, slices, maps, interfaces
+// and struct fields, including unexported ones. Values reachable more than once,
+// including through cycles, are copied once so the copy has the same shape.
+// Channels, functions and *time.Location are shared rather than copied.
+//
+// Values of types from sync and sync/atomic are left zero in the copy, so a
+// mutex held while copying comes out unlocked and a WaitGroup without waiters.
+// Their contents are not kept either: atomic counters, sync.Map entries and the
+// Locker of a sync.Cond must be set up again by the caller.
+//
+// Sharing is only detected between whole values. A pointer into a struct field
+// or slice element gets its own copy instead of pointing into the copied parent,
+// and slices over the same array with different bounds get separate arrays.
+func DeepCopy[T any](v T) T {
+	var dst T
+	c := copier{seen: map[visit]reflect.Value{}}
+	c.copy(reflect.ValueOf(&dst).Elem(), reflect.ValueOf(&v).Elem())
+	return dst
+}
+
+// visit identifies a pointer, map or slice that has already been copied
+type visit struct {
+	typ    reflect.Type
+	addr   uintptr
+	length int // Slices sharing an array but with different lengths are distinct
+}
+
+// copier holds the state of one deep copy
+type copier struct {
+	seen map[visit]reflect.Value
+}
+
+// locationType is shared because time.Time compares locations by pointer
+var locationType = reflect.TypeOf((*time.Location)(nil))
+
+// isSyncType reports whether t is a lock, counter or other type from sync or
+// sync/atomic, whose state must not be copied
+func isSyncType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && (t.PkgPath() == "sync" || t.PkgPath() == "sync/atomic")
+}
+
+// writable returns an addressable value that can be read and set even when it
+// was reached through an unexported struct field
+func writable(v reflect.Value) reflect.Value {
+	if !v.CanAddr() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// addressable copies v into a new addressable value so its fields can be read
+func addressable(v reflect.Value) reflect.Value {
+	tmp := reflect.New(v.Type()).Elem()
+	tmp.Set(v)
+	return tmp
+}
+
+// copy deep-copies src into dst; both must be writable and dst must be zero
+func (c *copier) copy(dst, src reflect.Value) {
+	if isSyncType(src.Type()) {
+		return
+	}
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		if src.Type() == locationType {
+			dst.Set(src)
+			return
+		}
+		key := visit{typ: src.Type(), addr: src.Pointer()}
+		if copied, ok := c.seen[key]; ok {
+			dst.Set(copied)
+			return
+		}
+		ptr := reflect.New(src.Type().Elem())
+		c.seen[key] = ptr
+		c.copy(ptr.Elem(), writable(src.Elem()))
+		dst.Set(ptr)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := src.Elem()
+		copied := reflect.New(elem.Type()).Elem()
+		c.copy(copied, addressable(elem))
+		dst.Set(copied)
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		key := visit{typ: src.Type(), addr: src.Pointer()}
+		if copied, ok := c.seen[key]; ok {
+			dst.Set(copied)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.seen[key] = m
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			c.copy(k, addressable(iter.Key()))
+			v := reflect.New(src.Type().Elem()).Elem()
+			c.copy(v, addressable(iter.Value()))
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		key := visit{typ: src.Type(), addr: src.Pointer(), length: src.Len()}
+		if copied, ok := c.seen[key]; ok {
+			dst.Set(copied)
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		c.seen[key] = s
+		for i := 0; i < src.Len(); i++ {
+			c.copy(writable(s.Index(i)), writable(src.Index(i)))
+		}
+		dst.Set(s)
+
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copy(writable(dst.Index(i)), writable(src.Index(i)))
+		}
+
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			c.copy(writable(dst.Field(i)), writable(src.Field(i)))
+		}
+
+	default:
+		// Basic kinds are copied by value; channels, functions and unsafe
+		// pointers cannot be duplicated meaningfully and are shared.
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeepCopyFollowsCycles(t *testing.T) {
+	doc := &Document{Title: "设计文档"}
+	doc.Sections = []*Section{{Heading: "背景", Doc: doc}, {Heading: "方案", Doc: doc}}
+
+	copied := DeepCopy(doc)
+	if copied == doc || copied.Sections[0] == doc.Sections[0] {
+		t.Fatal("the copy should not share pointers with the original")
+	}
+	for _, section := range copied.Sections {
+		if section.Doc != copied {
+			t.Fatal("back pointers should point at the copy")
+		}
+	}
+	doc.Sections[0].Heading = "动机"
+	if copied.Sections[0].Heading != "背景" {
+		t.Fatal("changing the original changed the copy")
+	}
+}
+
+func TestDeepCopyMaps(t *testing.T) {
+	type entry struct {
+		Tags []string
+	}
+	shared := &entry{Tags: []string{"草稿"}}
+	src := map[string]*entry{"a": shared, "b": shared}
+	var nilMap map[string]int
+
+	copied := DeepCopy(src)
+	if copied["a"] == shared {
+		t.Fatal("map values should be copied")
+	}
+	if copied["a"] != copied["b"] {
+		t.Fatal("a value reachable twice should be copied once")
+	}
+	shared.Tags[0] = "定稿"
+	if copied["a"].Tags[0] != "草稿" {
+		t.Fatal("changing the original changed the copy")
+	}
+	if DeepCopy(nilMap) != nil {
+		t.Fatal("a nil map should stay nil")
+	}
+}
+
+func TestDeepCopyInterfaces(t *testing.T) {
+	type holder struct {
+		Value any
+		Node  Node
+	}
+	node := &TextNode{state: "1"}
+	src := holder{Value: []int{1, 2}, Node: node}
+
+	copied := DeepCopy(src)
+	src.Value.([]int)[0] = 9
+	node.SetState("2")
+	if got := copied.Value.([]int); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("slice in interface = %v, want [1 2]", got)
+	}
+	if copied.Node == Node(node) || copied.Node.GetState() != "1" {
+		t.Fatalf("node in interface = %v, want a copy holding 1", copied.Node.GetState())
+	}
+	if DeepCopy(holder{}).Value != nil {
+		t.Fatal("a nil interface should stay nil")
+	}
+}
+
+func TestDeepCopyUnexportedFields(t *testing.T) {
+	type inner struct {
+		items []string
+	}
+	type outer struct {
+		name  string
+		inner *inner
+		pairs map[string]int
+	}
+	src := &outer{name: "a", inner: &inner{items: []string{"x"}}, pairs: map[string]int{"k": 1}}
+
+	copied := DeepCopy(src)
+	src.inner.items[0] = "y"
+	src.pairs["k"] = 2
+	if copied.name != "a" || copied.inner.items[0] != "x" || copied.pairs["k"] != 1 {
+		t.Fatalf("copy = %+v, want the unexported state at copy time", copied)
+	}
+}
+
+func TestDeepCopySharesLocationsAndChannels(t *testing.T) {
+	type event struct {
+		At   time.Time
+		Done chan struct{}
+	}
+	loc := time.FixedZone("CST", 8*3600)
+	src := event{At: time.Date(2024, 1, 1, 9, 0, 0, 0, loc), Done: make(chan struct{})}
+
+	copied := DeepCopy(src)
+	if !copied.At.Equal(src.At) || copied.At.Location() != loc {
+		t.Fatal("the time and its location should be kept")
+	}
+	if copied.Done != src.Done {
+		t.Fatal("channels should be shared")
+	}
+}
+
+func TestDeepCopyZeroesSyncTypes(t *testing.T) {
+	type counter struct {
+		mu    sync.Mutex
+		rw    *sync.RWMutex
+		wg    sync.WaitGroup
+		hits  atomic.Int64
+		count int
+	}
+	src := &counter{rw: &sync.RWMutex{}, count: 3}
+	src.hits.Store(7)
+	src.mu.Lock()
+	src.rw.Lock()
+	src.wg.Add(1)
+	defer src.wg.Done()
+
+	copied := DeepCopy(src)
+	src.mu.Unlock()
+	src.rw.Unlock()
+	if !copied.mu.TryLock() {
+		t.Fatal("a mutex locked while copying should be unlocked in the copy")
+	}
+	if copied.rw == src.rw || !copied.rw.TryLock() {
+		t.Fatal("a pointed-to RWMutex should be a fresh unlocked one")
+	}
+	copied.wg.Wait() // Returns at once, the copy has no pending work
+	if copied.hits.Load() != 0 || copied.count != 3 {
+		t.Fatalf("hits %d, count %d, want 0 and 3", copied.hits.Load(), copied.count)
+	}
+}
+
+func TestDeepCopyAliasingLimits(t *testing.T) {
+	type pair struct {
+		All  []int
+		Tail []int
+		Same []int
+	}
+	array := []int{1, 2, 3}
+	copied := DeepCopy(pair{All: array, Tail: array[1:], Same: array})
+
+	copied.All[1] = 9
+	if copied.Same[1] != 9 {
+		t.Fatal("identical slices should share the copied array")
+	}
+	if copied.Tail[0] != 2 {
+		t.Fatal("a subslice with other bounds gets its own array")
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	doc := &Document{Title: "设计文档", Tags: []string{"草稿"}, Meta: map[string]string{"作者": "枫枫"}}
+	checkpoint := TakeSnapshot(doc)
+	doc.Title = "设计文档 v2"
+	doc.Tags[0] = "定稿"
+
+	first, second := checkpoint.Restore(), checkpoint.Restore()
+	if first == second || first.Title != "设计文档" || first.Tags[0] != "草稿" {
+		t.Fatalf("restore = %+v, want an independent copy of the saved state", first)
+	}
+	first.Meta["作者"] = "张三"
+	if second.Meta["作者"] != "枫枫" || checkpoint.Restore().Meta["作者"] != "枫枫" {
+		t.Fatal("changing one restored value changed the snapshot")
+	}
+}
+
+func TestSnapshotRestoreInto(t *testing.T) {
+	doc := Document{Title: "设计文档", Tags: []string{"草稿"}}
+	checkpoint := TakeSnapshot(doc)
+	doc.Title = "设计文档 v2"
+	doc.Tags = append(doc.Tags, "定稿")
+
+	checkpoint.RestoreInto(&doc)
+	if doc.Title != "设计文档" || !reflect.DeepEqual(doc.Tags, []string{"草稿"}) {
+		t.Fatalf("doc = %+v, want the saved state", doc)
+	}
+	doc.Tags[0] = "定稿"
+	var again Document
+	checkpoint.RestoreInto(&again)
+	if again.Tags[0] != "草稿" {
+		t.Fatal("RestoreInto should not share state with the snapshot")
+	}
+}
+
+func TestSnapshotWithManage(t *testing.T) {
+	var history Manage
+	doc := Document{Title: "v1"}
+	history.Save(TakeSnapshot(doc))
+	doc.Title = "v2"
+	history.Save(TakeSnapshot(doc))
+
+	state, err := history.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.(*Snapshot[Document]).RestoreInto(&doc)
+	if doc.Title != "v1" {
+		t.Fatalf("title = %q, want v1", doc.Title)
+	}
+}
@@ -1,3 +1,3 @@
 module patterns_study
 
-go 1.20
+go 1.23